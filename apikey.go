@@ -0,0 +1,40 @@
+package webimizer
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+/*
+Allow only requests with valid API key in header (for example, X-API-Key), other requests get 401 Unauthorized status. validKeys is called on every request, so keys can be rotated without restart (keys with false value are not valid). Keys are compared in constant time
+Example:
+
+	http.Handle("/api/", app.HttpHandler(apiHandler).WithAPIKey("X-API-Key", func() map[string]bool {
+		return keys.Current()
+	}))
+*/
+func (fn HttpHandler) WithAPIKey(header string, validKeys func() map[string]bool) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if !validAPIKey(r.Header.Get(header), validKeys()) {
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		fn(rw, r)
+	})
+}
+
+/*
+Check key with all valid keys (all keys are compared, so time does not depend on which key matched)
+*/
+func validAPIKey(key string, validKeys map[string]bool) bool {
+	if key == "" {
+		return false
+	}
+	match := 0
+	for validKey, valid := range validKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(validKey)) == 1 && valid {
+			match = 1
+		}
+	}
+	return match == 1
+}
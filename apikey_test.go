@@ -0,0 +1,47 @@
+package webimizer
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestWithAPIKey(t *testing.T) {
+	var mu sync.Mutex
+	keys := map[string]bool{"key-1": true, "key-2": true, "disabled": false}
+	handler := HttpHandler(okHandler).WithAPIKey("X-API-Key", func() map[string]bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return keys
+	})
+	tests := []struct {
+		key  string
+		want int
+	}{
+		{"key-1", http.StatusOK},
+		{"key-2", http.StatusOK},
+		{"key-3", http.StatusUnauthorized},
+		{"disabled", http.StatusUnauthorized},
+		{"key", http.StatusUnauthorized},
+		{"", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.key != "" {
+			header.Set("X-API-Key", test.key)
+		}
+		if rec := serveRequest(handler, http.MethodGet, "/api/", header); rec.Code != test.want {
+			t.Errorf("key %q got %d, want %d", test.key, rec.Code, test.want)
+		}
+	}
+
+	// key-1 is rotated out without restart
+	mu.Lock()
+	keys = map[string]bool{"key-2": true, "key-3": true}
+	mu.Unlock()
+	for key, want := range map[string]int{"key-1": http.StatusUnauthorized, "key-2": http.StatusOK, "key-3": http.StatusOK} {
+		if rec := serveRequest(handler, http.MethodGet, "/api/", http.Header{"X-API-Key": {key}}); rec.Code != want {
+			t.Errorf("after rotation key %q got %d, want %d", key, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package webimizer
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+/*
+Error returned by request body reader, when body is sent slower than minimum rate of WithMinBodyRate
+*/
+var ErrBodyTooSlow = errors.New("webimizer: request body is sent too slowly")
+
+/*
+Time from the first body read, during which minimum body rate is not checked (connection can start slowly)
+*/
+const minBodyRateGrace = time.Second
+
+/*
+Protect from slow request body attack (slowloris): if request body is sent slower than bytesPerSec on average (after 1 second grace period), body reader returns ErrBodyTooSlow and 408 Request Timeout status is sent (if handler did not write response yet). Read deadline of connection is used (if supported), so blocked read is interrupted too
+*/
+func (fn HttpHandler) WithMinBodyRate(bytesPerSec int) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if bytesPerSec <= 0 || r.Body == nil || r.Body == http.NoBody {
+			fn(rw, r)
+			return
+		}
+		rc := http.NewResponseController(rw)
+		gw := &guardedResponseWriter{ResponseWriter: rw}
+		body := &minRateReader{r: r.Body, rate: bytesPerSec, w: gw}
+		if rc.SetReadDeadline(time.Time{}) == nil {
+			body.rc = rc
+			defer rc.SetReadDeadline(time.Time{})
+		}
+		r2 := r.Clone(r.Context())
+		r2.Body = body
+		fn(gw, r2)
+	})
+}
+
+/*
+Request body reader, which checks average read rate
+*/
+type minRateReader struct {
+	r     io.ReadCloser
+	rc    *http.ResponseController
+	rate  int
+	start time.Time
+	n     int64
+	w     *guardedResponseWriter
+	err   error
+}
+
+func (mr *minRateReader) Read(b []byte) (int, error) {
+	if mr.err != nil {
+		return 0, mr.err
+	}
+	if mr.start.IsZero() {
+		mr.start = time.Now()
+	}
+	if mr.rc != nil {
+		// next byte must arrive before average rate drops below minimum
+		mr.rc.SetReadDeadline(mr.start.Add(minBodyRateGrace + time.Duration(float64(mr.n+1)/float64(mr.rate)*float64(time.Second))))
+	}
+	n, err := mr.r.Read(b)
+	mr.n += int64(n)
+	elapsed := time.Since(mr.start)
+	if errors.Is(err, os.ErrDeadlineExceeded) || (err == nil && float64(mr.n) < float64(mr.rate)*(elapsed-minBodyRateGrace).Seconds()) {
+		mr.err = ErrBodyTooSlow
+		mr.w.Header().Set("Connection", "close")
+		mr.w.abort(http.StatusRequestTimeout)
+		return 0, mr.err
+	}
+	return n, err
+}
+
+func (mr *minRateReader) Close() error {
+	return mr.r.Close()
+}
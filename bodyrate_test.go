@@ -0,0 +1,66 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+Request body, which returns one byte per read after delay
+*/
+type slowBody struct {
+	delay time.Duration
+	left  int
+}
+
+func (b *slowBody) Read(p []byte) (int, error) {
+	if b.left == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(b.delay)
+	b.left--
+	p[0] = 'a'
+	return 1, nil
+}
+
+func (b *slowBody) Close() error {
+	return nil
+}
+
+func TestWithMinBodyRate(t *testing.T) {
+	var readErr error
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+		if readErr != nil {
+			http.Error(rw, readErr.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(rw, "uploaded")
+	}).WithMinBodyRate(1000)
+
+	r := httptest.NewRequest(http.MethodPost, "/upload", nil)
+	r.Body = &slowBody{delay: 100 * time.Millisecond, left: 100}
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rec, r)
+	if readErr != ErrBodyTooSlow {
+		t.Errorf("body read error %v, want ErrBodyTooSlow", readErr)
+	}
+	if rec.Code != http.StatusRequestTimeout || rec.Header().Get("Connection") != "close" {
+		t.Errorf("status %d, Connection %q, want 408 and close", rec.Code, rec.Header().Get("Connection"))
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("slow body aborted after %v", elapsed)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("a", 100000)))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if readErr != nil || rec.Code != http.StatusOK || rec.Body.String() != "uploaded" {
+		t.Errorf("fast body: error %v, status %d, body %q", readErr, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,124 @@
+package webimizer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Create brotli compressing writer (Go standard library has no brotli encoder, so any implementation can be used). FileServerStruct.CompressBrotli option works only if it is set
+Example:
+
+	app.NewBrotliWriter = func(w io.Writer) io.WriteCloser {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression) // github.com/andybalholm/brotli
+	}
+*/
+var NewBrotliWriter func(w io.Writer) io.WriteCloser
+
+/*
+Define Content-Type prefixes of files, which are compressed with brotli on the fly by file server (binary formats like images and archives are already compressed). GzipExcludedContentTypes are not compressed too
+*/
+var BrotliContentTypes = []string{
+	"text/",
+	"application/javascript",
+	"application/json",
+	"application/manifest+json",
+	"application/xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+/*
+Maximum size of file, which is compressed with brotli on the fly (compressed files are cached in memory). Bigger files are compressed with gzip by ServeHTTP
+*/
+const brotliMaxFileSize = 1 << 20
+
+type brotliEntry struct {
+	modTime time.Time
+	size    int64
+	data    []byte
+}
+
+/*
+Cache of brotli compressed files, which are invalidated when file modification time or size changes
+*/
+type brotliCache struct {
+	mu      sync.Mutex
+	entries map[string]brotliEntry
+}
+
+/*
+Get brotli compressed file, if client prefers br encoding (over gzip) and Content-Type of file is listed in BrotliContentTypes. If compressed file is returned, Content-Encoding and Content-Type headers are set and path of compressed file (for content ETag) is returned too. File, which is not cached yet, is not compressed for HEAD requests, so empty path is returned (ETag of compressed file is not known)
+*/
+func (nfs neuteredFileSystem) openBrotli(name string, f http.File) (http.File, string) {
+	if !nfs.config.CompressBrotli || NewBrotliWriter == nil || nfs.brotli == nil {
+		return nil, ""
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if !brotliContentType(contentType) {
+		return nil, ""
+	}
+	addVary(nfs.w.Header(), "Accept-Encoding")
+	acceptEncoding := nfs.r.Header.Get("Accept-Encoding")
+	br := acceptEncodingQuality(acceptEncoding, "br")
+	if br <= 0 || br < acceptEncodingQuality(acceptEncoding, "gzip") {
+		return nil, ""
+	}
+	s, err := f.Stat()
+	if err != nil || s.Size() > brotliMaxFileSize {
+		return nil, ""
+	}
+	nfs.brotli.mu.Lock()
+	entry, ok := nfs.brotli.entries[name]
+	nfs.brotli.mu.Unlock()
+	if !ok || !entry.modTime.Equal(s.ModTime()) || entry.size != s.Size() {
+		if nfs.r.Method == http.MethodHead {
+			// body is not sent, so file is not compressed (Content-Length is not sent with Content-Encoding anyway)
+			nfs.w.Header().Set("Content-Encoding", "br")
+			nfs.w.Header().Set("Content-Type", contentType)
+			disableGzip(nfs.w)
+			return &memoryFile{Reader: bytes.NewReader(nil), info: memoryFileInfo{name: s.Name(), modTime: s.ModTime()}}, ""
+		}
+		var buf bytes.Buffer
+		bw := NewBrotliWriter(&buf)
+		_, err := io.Copy(bw, f)
+		if closeErr := bw.Close(); err == nil {
+			err = closeErr
+		}
+		if _, seekErr := f.Seek(0, io.SeekStart); err != nil || seekErr != nil {
+			return nil, ""
+		}
+		entry = brotliEntry{modTime: s.ModTime(), size: s.Size(), data: buf.Bytes()}
+		nfs.brotli.mu.Lock()
+		nfs.brotli.entries[name] = entry
+		nfs.brotli.mu.Unlock()
+	}
+	nfs.w.Header().Set("Content-Encoding", "br")
+	nfs.w.Header().Set("Content-Type", contentType)
+	disableGzip(nfs.w)
+	return &memoryFile{Reader: bytes.NewReader(entry.data), info: memoryFileInfo{name: s.Name(), size: int64(len(entry.data)), modTime: s.ModTime()}}, name + ".br"
+}
+
+func brotliContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if contentType == "" {
+		return false
+	}
+	for _, excluded := range GzipExcludedContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(excluded)) {
+			return false
+		}
+	}
+	for _, prefix := range BrotliContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+/*
+Stub brotli writer, which wraps data in "br(" and ")"
+*/
+type stubBrotliWriter struct {
+	w io.Writer
+}
+
+func (bw stubBrotliWriter) Write(b []byte) (int, error) {
+	return bw.w.Write(b)
+}
+
+func (bw stubBrotliWriter) Close() error {
+	_, err := io.WriteString(bw.w, ")")
+	return err
+}
+
+func TestCompressBrotli(t *testing.T) {
+	compressions := 0
+	setForTest(t, &NewBrotliWriter, func(w io.Writer) io.WriteCloser {
+		compressions++
+		io.WriteString(w, "br(")
+		return stubBrotliWriter{w: w}
+	})
+	handler := FileServerStruct{
+		FileSystem:     newMemoryFileSystem(map[string][]byte{"/app.js": []byte("console.log(1)"), "/logo.png": []byte("\x89PNG")}),
+		CompressBrotli: true,
+	}.Build()
+
+	for i := 0; i < 2; i++ {
+		rec := serveRequest(handler, http.MethodGet, "/app.js", http.Header{"Accept-Encoding": {"br"}})
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "br(console.log(1))" {
+			t.Fatalf("got %d %q %q, want brotli compressed file", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		if vary := rec.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+			t.Errorf("Vary = %q, want Accept-Encoding", vary)
+		}
+	}
+	if compressions != 1 {
+		t.Errorf("file compressed %d times, want 1 (cached)", compressions)
+	}
+
+	rec := serveRequest(handler, http.MethodGet, "/logo.png", http.Header{"Accept-Encoding": {"br"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "\x89PNG" {
+		t.Errorf("binary file got Content-Encoding %q, want uncompressed", rec.Header().Get("Content-Encoding"))
+	}
+	rec = serveRequest(handler, http.MethodGet, "/app.js", http.Header{"Accept-Encoding": {"br;q=0.5, gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" || gunzip(t, rec.Body.Bytes()) != "console.log(1)" {
+		t.Errorf("gzip preferring client got Content-Encoding %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCompressBrotliHead(t *testing.T) {
+	compressions := 0
+	setForTest(t, &NewBrotliWriter, func(w io.Writer) io.WriteCloser {
+		compressions++
+		io.WriteString(w, "br(")
+		return stubBrotliWriter{w: w}
+	})
+	var read atomic.Int64
+	fsys := countingFileSystem{FileSystem: newMemoryFileSystem(map[string][]byte{"/app.js": []byte("console.log(1)")}), read: &read}
+	handler := FileServerStruct{FileSystem: fsys, CompressBrotli: true}.Build()
+
+	rec := serveRequest(handler, http.MethodHead, "/app.js", http.Header{"Accept-Encoding": {"br"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "br" || rec.Body.Len() != 0 {
+		t.Fatalf("HEAD got %d %q %q, want 200 with br Content-Encoding and no body", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+		t.Errorf("HEAD Content-Type = %q", ct)
+	}
+	if compressions != 0 || read.Load() != 0 {
+		t.Errorf("HEAD compressed file %d times and read %d bytes, want none", compressions, read.Load())
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/app.js", http.Header{"Accept-Encoding": {"br"}})
+	if rec.Body.String() != "br(console.log(1))" || compressions != 1 {
+		t.Errorf("GET after HEAD got %q (compressed %d times), want brotli compressed file", rec.Body.String(), compressions)
+	}
+}
+
+func TestCompressBrotliHeadETag(t *testing.T) {
+	setForTest(t, &NewBrotliWriter, func(w io.Writer) io.WriteCloser {
+		io.WriteString(w, "br(")
+		return stubBrotliWriter{w: w}
+	})
+	sources := map[string]http.FileSystem{
+		"memfs": newMemoryFileSystem(map[string][]byte{"/a.js": []byte("console.log('a')"), "/b.js": []byte("console.log('b')")}),
+		"MapFS": http.FS(fstest.MapFS{"a.js": {Data: []byte("console.log('a')")}, "b.js": {Data: []byte("console.log('b')")}}),
+	}
+	for name, fsys := range sources {
+		handler := FileServerStruct{FileSystem: fsys, CompressBrotli: true}.Build()
+		br := http.Header{"Accept-Encoding": {"br"}}
+		etags := map[string]string{}
+		for _, path := range []string{"/a.js", "/b.js"} {
+			rec := serveRequest(handler, http.MethodHead, path, br)
+			if got := rec.Header().Get("ETag"); got != "" {
+				t.Errorf("%s: HEAD %s of uncached file ETag = %q, want absent", name, path, got)
+			}
+			rec = serveRequest(handler, http.MethodGet, path, br)
+			if want := contentHashETag(rec.Body.Bytes()); rec.Header().Get("ETag") != want {
+				t.Errorf("%s: GET %s ETag = %q, want %q", name, path, rec.Header().Get("ETag"), want)
+			}
+			etags[path] = rec.Header().Get("ETag")
+		}
+		if etags["/a.js"] == etags["/b.js"] {
+			t.Errorf("%s: different files have the same ETag %q", name, etags["/a.js"])
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package webimizer
+
+import (
+	"bytes"
+	"net/http"
+)
+
+/*
+Define maximum size of response, which is buffered in memory by WithBuffering. If response is bigger, buffered data is sent to client and the rest of response is written without buffering (status and headers can not be changed anymore)
+*/
+var MaxBufferedResponseSize = 4 << 20
+
+/*
+http.ResponseWriter, which buffers response body and status until handler returns
+*/
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	buf      *bytes.Buffer
+	status   int
+	overflow bool
+}
+
+func (w *bufferedResponseWriter) WriteHeader(status int) {
+	if status >= 100 && status < 200 {
+		// informational response (for example, 103 Early Hints) is not buffered
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.overflow {
+		return
+	}
+	w.status = status
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if w.overflow {
+		return w.ResponseWriter.Write(b)
+	}
+	if w.buf.Len()+len(b) > MaxBufferedResponseSize {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+		w.overflow = true
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+/*
+Flush is ignored while response is buffered (status must not be sent before handler returns). After buffer overflow, response is flushed to client
+*/
+func (w *bufferedResponseWriter) Flush() {
+	if w.overflow {
+		flushResponse(w.ResponseWriter)
+	}
+}
+
+func (w *bufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*
+Send buffered status and body to underlying http.ResponseWriter
+*/
+func (w *bufferedResponseWriter) flush() error {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+/*
+Buffer the entire response in memory (up to MaxBufferedResponseSize bytes), so handler can set headers and status at any point (for example, after writing body). Response is sent when handler returns
+*/
+func (fn HttpHandler) WithBuffering() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		bw := &bufferedResponseWriter{ResponseWriter: rw, buf: getBuffer()}
+		defer putBuffer(bw.buf)
+		fn(bw, r)
+		if !bw.overflow {
+			bw.flush()
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithBufferingStatusAfterBody(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "created")
+		rw.Header().Set("Location", "/items/1")
+		rw.WriteHeader(http.StatusCreated)
+	}).WithBuffering()
+	rec := serveRequest(handler, http.MethodGet, "/", nil)
+	if rec.Code != http.StatusCreated || rec.Body.String() != "created" || rec.Header().Get("Location") != "/items/1" {
+		t.Errorf("got %d %q Location %q, want 201 created /items/1", rec.Code, rec.Body.String(), rec.Header().Get("Location"))
+	}
+}
+
+func TestWithBufferingIgnoresFlush(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "created")
+		flushResponse(rw)
+		if err := http.NewResponseController(rw).Flush(); err != nil {
+			t.Errorf("Flush() error: %v", err)
+		}
+		rw.WriteHeader(http.StatusCreated)
+	}).WithBuffering()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusCreated || rec.Flushed {
+		t.Errorf("got %d (flushed %v), want 201 without flush", rec.Code, rec.Flushed)
+	}
+}
+
+func TestWithBufferingGzip(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		io.WriteString(rw, strings.Repeat("buffered ", 10))
+		rw.WriteHeader(http.StatusAccepted)
+	}).WithBuffering()
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Code != http.StatusAccepted || gunzip(t, rec.Body.Bytes()) != strings.Repeat("buffered ", 10) {
+		t.Errorf("got %d, want compressed 202 response", rec.Code)
+	}
+}
+
+func TestWithBufferingOverflow(t *testing.T) {
+	setForTest(t, &MaxBufferedResponseSize, 4)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "too long body")
+		rw.WriteHeader(http.StatusCreated)
+	}).WithBuffering()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "too long body" {
+		t.Errorf("got %d %q, want 200 with full body", rec.Code, rec.Body.String())
+	}
+}
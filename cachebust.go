@@ -0,0 +1,167 @@
+package webimizer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+src and href attributes with quoted value in HTML document
+*/
+var assetLinkPattern = regexp.MustCompile(`(?i)(\s(?:src|href)\s*=\s*)("[^"]*"|'[^']*')`)
+
+type assetHash struct {
+	modTime time.Time
+	size    int64
+	hash    string
+}
+
+/*
+Rewritten HTML document with hashes of linked assets (empty hash for missing asset), which were used to rewrite it
+*/
+type bustedDocument struct {
+	modTime time.Time
+	size    int64
+	assets  map[string]string
+	content []byte
+}
+
+/*
+Cache of asset content hashes and rewritten HTML documents, which are invalidated when file modification time or size changes (documents are invalidated when hash of linked asset changes too)
+*/
+type assetHashCache struct {
+	mu        sync.Mutex
+	hashes    map[string]assetHash
+	documents map[string]bustedDocument
+}
+
+/*
+Get short content hash of file, which is served for requested path name (empty string if file is hidden, can not be read or it is directory)
+*/
+func (nfs neuteredFileSystem) assetHash(name string) string {
+	name, ok := nfs.resolvePath(name)
+	if !ok {
+		return ""
+	}
+	f, err := nfs.fs.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	s, err := f.Stat()
+	if err != nil || s.IsDir() {
+		return ""
+	}
+	cache := nfs.assetHashes
+	cache.mu.Lock()
+	cached, ok := cache.hashes[name]
+	cache.mu.Unlock()
+	if ok && cached.modTime.Equal(s.ModTime()) && cached.size == s.Size() {
+		return cached.hash
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	hash := hex.EncodeToString(h.Sum(nil)[:6])
+	cache.mu.Lock()
+	cache.hashes[name] = assetHash{modTime: s.ModTime(), size: s.Size(), hash: hash}
+	cache.mu.Unlock()
+	return hash
+}
+
+/*
+Read HTML file and append ?v=<content hash> to relative asset links (src and href attributes), which point to existing files. Rewritten document is returned as in-memory file with ETag header, so conditional requests follow asset changes too. Rewritten document is cached, so it is not read again (for example, for HEAD requests), until it or linked asset changes. If file can not be read, f is returned unchanged
+*/
+func (nfs neuteredFileSystem) cacheBustHTML(name string, f http.File) http.File {
+	s, err := f.Stat()
+	if err != nil {
+		return f
+	}
+	cache := nfs.assetHashes
+	cache.mu.Lock()
+	doc, ok := cache.documents[name]
+	cache.mu.Unlock()
+	if !ok || !doc.modTime.Equal(s.ModTime()) || doc.size != s.Size() || !nfs.assetsUnchanged(doc.assets) {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			f.Seek(0, io.SeekStart)
+			return f
+		}
+		doc = bustedDocument{modTime: s.ModTime(), size: s.Size(), assets: map[string]string{}}
+		doc.content = nfs.rewriteAssetLinks(path.Dir(name), content, doc.assets)
+		cache.mu.Lock()
+		cache.documents[name] = doc
+		cache.mu.Unlock()
+	}
+	f.Close()
+	nfs.w.Header().Set("ETag", contentHashETag(doc.content))
+	return &memoryFile{Reader: bytes.NewReader(doc.content), info: memoryFileInfo{name: s.Name(), size: int64(len(doc.content))}}
+}
+
+/*
+Check if linked assets have the same hashes as when document was rewritten
+*/
+func (nfs neuteredFileSystem) assetsUnchanged(assets map[string]string) bool {
+	for target, hash := range assets {
+		if nfs.assetHash(target) != hash {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Append ?v=<content hash> to relative asset links of HTML document in directory dir. Hashes of linked assets are saved to assets
+*/
+func (nfs neuteredFileSystem) rewriteAssetLinks(dir string, content []byte, assets map[string]string) []byte {
+	return assetLinkPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		parts := assetLinkPattern.FindSubmatch(match)
+		quoted := string(parts[2])
+		link := quoted[1 : len(quoted)-1]
+		target, ok := localAssetPath(dir, link)
+		if !ok {
+			return match
+		}
+		hash := nfs.assetHash(target)
+		assets[target] = hash
+		if hash == "" {
+			return match
+		}
+		// fragment must stay at the end of link
+		fragment := ""
+		if i := strings.Index(link, "#"); i >= 0 {
+			link, fragment = link[:i], link[i:]
+		}
+		separator := "?"
+		if strings.Contains(link, "?") {
+			separator = "&"
+		}
+		rewritten := link + separator + "v=" + hash + fragment
+		return append(append([]byte{}, parts[1]...), quoted[:1]+rewritten+quoted[:1]...)
+	})
+}
+
+/*
+Get file path of same-origin relative link (for example, "app.js" or "/css/style.css"). Links with scheme, host, existing v query parameter or without path are not local assets
+*/
+func localAssetPath(dir, link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || u.Path == "" || u.Query().Has("v") {
+		return "", false
+	}
+	p := u.Path
+	if !strings.HasPrefix(p, "/") {
+		p = path.Join(dir, p)
+	}
+	return cleanPath(p), true
+}
@@ -0,0 +1,97 @@
+package webimizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func shortHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:6])
+}
+
+func TestCacheBustAssets(t *testing.T) {
+	const page = `<html><head>
+<link rel="stylesheet" href="css/style.css">
+<script src='/app.js?lang=en#main'></script>
+<script src="https://cdn.example.com/lib.js"></script>
+<img src="missing.png">
+<a href="//example.org/app.js">cdn</a>
+</head></html>`
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/index.html":    []byte(page),
+			"/css/style.css": []byte("body{}"),
+			"/app.js":        []byte("console.log(1)"),
+		}),
+		CacheBustAssets: true,
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/", nil)
+	want := `<html><head>
+<link rel="stylesheet" href="css/style.css?v=` + shortHash("body{}") + `">
+<script src='/app.js?lang=en&v=` + shortHash("console.log(1)") + `#main'></script>
+<script src="https://cdn.example.com/lib.js"></script>
+<img src="missing.png">
+<a href="//example.org/app.js">cdn</a>
+</head></html>`
+	if rec.Code != http.StatusOK || rec.Body.String() != want {
+		t.Errorf("got %d\n%s\nwant\n%s", rec.Code, rec.Body.String(), want)
+	}
+	if rec.Header().Get("ETag") != contentHashETag([]byte(want)) {
+		t.Errorf("ETag = %q, want hash of rewritten document", rec.Header().Get("ETag"))
+	}
+
+	if got := serveRequest(handler, http.MethodGet, "/app.js", nil).Body.String(); got != "console.log(1)" {
+		t.Errorf("asset body = %q, want unchanged", got)
+	}
+}
+
+func TestCacheBustAssetsRules(t *testing.T) {
+	const page = `<script src="/.config.js"></script>
+<script src="app.js#main?debug"></script>`
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/index.html":    []byte(page),
+			"/index.html.gz": []byte("stale precompressed document"),
+			"/.config.js":    []byte("secret"),
+			"/app.js":        []byte("console.log(1)"),
+		}),
+		CacheBustAssets: true,
+		HideDotFiles:    true,
+		Precompressed:   true,
+	}.Build()
+	want := `<script src="/.config.js"></script>
+<script src="app.js?v=` + shortHash("console.log(1)") + `#main?debug"></script>`
+	rec := serveRequest(handler, http.MethodGet, "/", nil)
+	if rec.Body.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", rec.Body.String(), want)
+	}
+	rec = serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" || gunzip(t, rec.Body.Bytes()) != want {
+		t.Errorf("gzip client got Content-Encoding %q, want rewritten document instead of precompressed sibling", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestCacheBustAssetsHead(t *testing.T) {
+	var read atomic.Int64
+	page := `<script src="app.js"></script>` + strings.Repeat("<p>content</p>\n", 1000)
+	fsys := countingFileSystem{FileSystem: newMemoryFileSystem(map[string][]byte{
+		"/index.html": []byte(page),
+		"/app.js":     []byte("console.log(1)"),
+	}), read: &read}
+	handler := FileServerStruct{FileSystem: fsys, CacheBustAssets: true}.Build()
+	get := serveRequest(handler, http.MethodGet, "/", nil)
+	read.Store(0)
+	head := serveRequest(handler, http.MethodHead, "/", nil)
+	if n := read.Load(); n != 0 {
+		t.Errorf("HEAD read %d bytes, want rewritten document from cache", n)
+	}
+	if head.Header().Get("ETag") != get.Header().Get("ETag") || head.Header().Get("Content-Length") != strconv.Itoa(get.Body.Len()) {
+		t.Errorf("HEAD ETag %q Content-Length %q, want GET ETag %q and length %d", head.Header().Get("ETag"), head.Header().Get("Content-Length"), get.Header().Get("ETag"), get.Body.Len())
+	}
+}
@@ -0,0 +1,55 @@
+package webimizer
+
+import (
+	"bytes"
+	"net/http"
+)
+
+/*
+http.ResponseWriter, which captures whole response (headers, status and body) in memory, so it can be sent later (possibly several times)
+*/
+type capturedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newCapturedResponse() *capturedResponse {
+	return &capturedResponse{header: make(http.Header)}
+}
+
+func (c *capturedResponse) Header() http.Header {
+	return c.header
+}
+
+func (c *capturedResponse) WriteHeader(status int) {
+	if c.status == 0 && status >= 200 {
+		c.status = status
+	}
+}
+
+func (c *capturedResponse) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	return c.body.Write(b)
+}
+
+/*
+Send captured response to w. Headers listed in skipHeaders are not copied
+*/
+func (c *capturedResponse) writeTo(w http.ResponseWriter, skipHeaders ...string) {
+	dst := w.Header()
+	for k, v := range c.header {
+		dst[k] = append([]string(nil), v...)
+	}
+	for _, k := range skipHeaders {
+		dst.Del(k)
+	}
+	status := c.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(c.body.Bytes())
+}
@@ -0,0 +1,73 @@
+package webimizer
+
+import (
+	"net/http"
+)
+
+/*
+Create HttpHandler, which calls primary handler (for example, API routes) and, if it responds with 404 status, calls fallback handler (for example, file server) instead. 404 response of primary handler is not sent to client
+Example:
+
+	http.Handle("/", app.FallthroughHandler(apiHandler, app.NewFileServerHandler("./static")))
+*/
+func FallthroughHandler(primary HttpHandler, fallback HttpHandler) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		saved := rw.Header().Clone()
+		fw := &fallthroughWriter{ResponseWriter: rw}
+		primary(fw, r)
+		if !fw.notFound {
+			return
+		}
+		// headers set by primary handler for 404 response must not be sent
+		header := rw.Header()
+		for k := range header {
+			delete(header, k)
+		}
+		for k, v := range saved {
+			header[k] = v
+		}
+		fallback(rw, r)
+	})
+}
+
+/*
+http.ResponseWriter, which discards 404 response
+*/
+type fallthroughWriter struct {
+	http.ResponseWriter
+	written  bool
+	notFound bool
+}
+
+func (w *fallthroughWriter) WriteHeader(status int) {
+	if w.written {
+		if !w.notFound {
+			w.ResponseWriter.WriteHeader(status)
+		}
+		return
+	}
+	if status >= 100 && status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.written = true
+	if status == http.StatusNotFound {
+		w.notFound = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *fallthroughWriter) Write(b []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.notFound {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *fallthroughWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
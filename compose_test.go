@@ -0,0 +1,49 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestFallthroughHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/x", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		io.WriteString(rw, `{"api":"x"}`)
+	})
+	api := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Api-Version", "1")
+		mux.ServeHTTP(rw, r)
+	})
+	files := NewMemoryFileServerHandler(map[string][]byte{"/index.html": []byte("<h1>static</h1>"), "/about.html": []byte("<h1>about</h1>")})
+	handler := FallthroughHandler(api, files)
+
+	rec := serveRequest(handler, http.MethodGet, "/api/x", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"api":"x"}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("/api/x got %d %q %q, want API response", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/index.html", nil)
+	// http.FileServer redirects /index.html to directory
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "./" {
+		t.Errorf("/index.html got %d %q, want file server redirect", rec.Code, rec.Header().Get("Location"))
+	}
+	rec = serveRequest(handler, http.MethodGet, "/", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "<h1>static</h1>" {
+		t.Errorf("/ got %d %q, want static index.html", rec.Code, rec.Body.String())
+	}
+	if rec.Header().Get("X-Api-Version") != "" || rec.Header().Get("X-Content-Type-Options") != "" {
+		t.Errorf("headers of discarded API 404 response are sent: %v", rec.Header())
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/about.html", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "<h1>about</h1>" {
+		t.Errorf("/about.html got %d %q, want static file", rec.Code, rec.Body.String())
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/missing.css", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("/missing.css got %d, want 404 of file server", rec.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package webimizer
+
+import (
+	"net/http"
+	"time"
+)
+
+/*
+Set Last-Modified response header and check If-Modified-Since request header. If client has current version, 304 Not Modified status is written and true is returned (handler must return without writing body). Conditional request is checked only for safe methods (GET and HEAD), so other methods (for example, POST or PUT) are always processed
+Example:
+
+	if app.CheckLastModified(rw, r, page.UpdatedAt) {
+		return
+	}
+*/
+func CheckLastModified(w http.ResponseWriter, r *http.Request, modtime time.Time) bool {
+	if modtime.IsZero() || modtime.Equal(time.Unix(0, 0)) {
+		return false
+	}
+	modtime = modtime.Truncate(time.Second)
+	w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	if !conditionalMethod(r.Method) {
+		return false
+	}
+	if r.Header.Get("If-None-Match") != "" {
+		// If-None-Match has precedence over If-Modified-Since (RFC 7232)
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modtime.After(since) {
+		return false
+	}
+	writeNotModified(w)
+	return true
+}
+
+/*
+Write 304 Not Modified status without body headers
+*/
+func writeNotModified(w http.ResponseWriter) {
+	h := w.Header()
+	h.Del("Content-Type")
+	h.Del("Content-Length")
+	w.WriteHeader(http.StatusNotModified)
+}
+
+/*
+Check if conditional request headers (If-None-Match and If-Modified-Since) can be used with request method (only safe methods GET and HEAD)
+*/
+func conditionalMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
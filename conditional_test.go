@@ -0,0 +1,96 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testModTime = time.Date(2024, time.March, 10, 12, 30, 45, 0, time.UTC)
+
+/*
+Call CheckLastModified with request, which has If-Modified-Since header (if since is not zero)
+*/
+func checkLastModified(method string, since time.Time) (bool, *httptest.ResponseRecorder) {
+	r := httptest.NewRequest(method, "/page", nil)
+	if !since.IsZero() {
+		r.Header.Set("If-Modified-Since", since.Format(http.TimeFormat))
+	}
+	rec := httptest.NewRecorder()
+	return CheckLastModified(rec, r, testModTime.Add(250*time.Millisecond)), rec
+}
+
+func TestCheckLastModified(t *testing.T) {
+	tests := []struct {
+		name  string
+		since time.Time
+		want  bool
+	}{
+		{"no If-Modified-Since", time.Time{}, false},
+		{"fresh same time", testModTime, true},
+		{"fresh later time", testModTime.Add(time.Hour), true},
+		{"stale", testModTime.Add(-time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rec := checkLastModified(http.MethodGet, tt.since)
+			if got != tt.want {
+				t.Fatalf("CheckLastModified() = %v, want %v", got, tt.want)
+			}
+			if lm := rec.Header().Get("Last-Modified"); lm != testModTime.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %q", lm)
+			}
+			if tt.want && rec.Code != http.StatusNotModified {
+				t.Errorf("status = %d, want 304", rec.Code)
+			}
+		})
+	}
+}
+
+func TestCheckLastModifiedUnsafeMethods(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		got, rec := checkLastModified(method, testModTime.Add(time.Hour))
+		if got || rec.Code != http.StatusOK {
+			t.Errorf("%s: CheckLastModified() = %v with status %d, want false without 304", method, got, rec.Code)
+		}
+		if rec.Header().Get("Last-Modified") == "" {
+			t.Errorf("%s: Last-Modified is not set", method)
+		}
+	}
+	if got, rec := checkLastModified(http.MethodHead, testModTime.Add(time.Hour)); !got || rec.Code != http.StatusNotModified {
+		t.Errorf("HEAD: CheckLastModified() = %v with status %d, want 304", got, rec.Code)
+	}
+}
+
+func TestContentHandlersUnsafeMethods(t *testing.T) {
+	staticJSON, err := StaticJSONHandler(map[string]string{"name": "webimizer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	precomputed := NewPrecomputedResponseHandler()
+	precomputed.Register("/", http.StatusOK, "application/json", []byte(`{"name":"webimizer"}`))
+	handlers := map[string]HttpHandler{
+		"StaticJSONHandler":          staticJSON,
+		"PrecomputedResponseHandler": precomputed.Handler(),
+		"FaviconHandler":             FaviconHandler([]byte{0, 0, 1, 0, 1, 0}),
+	}
+	for name, handler := range handlers {
+		etag := serveRequest(handler, http.MethodGet, "/", nil).Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: GET response has no ETag", name)
+		}
+		if rec := serveRequest(handler, http.MethodGet, "/", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+			t.Errorf("%s: conditional GET got %d, want 304", name, rec.Code)
+		}
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			rec := serveRequest(handler, method, "/", http.Header{"If-None-Match": {etag}})
+			if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+				t.Errorf("%s: %s with If-None-Match got %d with %d body bytes, want 200 with body", name, method, rec.Code, rec.Body.Len())
+			}
+			if got := rec.Header().Get("ETag"); got != "" {
+				t.Errorf("%s: %s response ETag = %q, want absent", name, method, got)
+			}
+		}
+	}
+}
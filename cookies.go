@@ -0,0 +1,61 @@
+package webimizer
+
+import (
+	"net/http"
+	"strings"
+)
+
+/*
+Remove duplicate Set-Cookie headers (set by several middleware or handler) just before response headers are sent: for cookies with the same name, Domain and Path, only the last one is sent (browser would keep only the last one anyway)
+Example:
+
+	http.Handle("/", app.HttpHandler(handler).WithCSRF(secret).WithCookieDedupe())
+*/
+func (fn HttpHandler) WithCookieDedupe() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		serveWithHeaderHook(rw, r, fn, dedupeSetCookies)
+	})
+}
+
+func dedupeSetCookies(h http.Header) {
+	cookies := h.Values("Set-Cookie")
+	if len(cookies) < 2 {
+		return
+	}
+	last := map[string]int{}
+	for i, cookie := range cookies {
+		last[setCookieKey(cookie)] = i
+	}
+	if len(last) == len(cookies) {
+		return
+	}
+	deduped := make([]string, 0, len(last))
+	for i, cookie := range cookies {
+		if last[setCookieKey(cookie)] == i {
+			deduped = append(deduped, cookie)
+		}
+	}
+	h["Set-Cookie"] = deduped
+}
+
+/*
+Get identity of cookie in Set-Cookie header value: name, Domain and Path attributes
+*/
+func setCookieKey(setCookie string) string {
+	parts := strings.Split(setCookie, ";")
+	name := strings.TrimSpace(strings.SplitN(parts[0], "=", 2)[0])
+	domain, cookiePath := "", ""
+	for _, attr := range parts[1:] {
+		kv := strings.SplitN(strings.TrimSpace(attr), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToLower(kv[0]) {
+		case "domain":
+			domain = strings.ToLower(strings.TrimPrefix(kv[1], "."))
+		case "path":
+			cookiePath = kv[1]
+		}
+	}
+	return name + ";" + domain + ";" + cookiePath
+}
@@ -0,0 +1,41 @@
+package webimizer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWithCookieDedupe(t *testing.T) {
+	outer := func(next HttpHandler) HttpHandler {
+		return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+			http.SetCookie(rw, &http.Cookie{Name: "session", Value: "outer", Path: "/"})
+			http.SetCookie(rw, &http.Cookie{Name: "theme", Value: "dark", Path: "/"})
+			next(rw, r)
+		})
+	}
+	handler := outer(HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "inner", Path: "/"})
+		// same name with other path is other cookie
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "admin", Path: "/admin"})
+		rw.Write([]byte("ok"))
+	})).WithCookieDedupe()
+
+	rec := serveRequest(handler, http.MethodGet, "/", nil)
+	cookies := rec.Result().Cookies()
+	values := map[string][]string{}
+	for _, cookie := range cookies {
+		values[cookie.Name+" "+cookie.Path] = append(values[cookie.Name+" "+cookie.Path], cookie.Value)
+	}
+	if len(cookies) != 3 {
+		t.Errorf("got %d Set-Cookie headers, want 3: %q", len(cookies), rec.Header().Values("Set-Cookie"))
+	}
+	if got := values["session /"]; len(got) != 1 || got[0] != "inner" {
+		t.Errorf("session cookie values %q, want last one only", got)
+	}
+	if got := values["session /admin"]; len(got) != 1 || got[0] != "admin" {
+		t.Errorf("session cookie of /admin values %q, want admin", got)
+	}
+	if got := values["theme /"]; len(got) != 1 || got[0] != "dark" {
+		t.Errorf("theme cookie values %q, want dark", got)
+	}
+}
@@ -0,0 +1,66 @@
+package webimizer
+
+import (
+	"net/http"
+)
+
+/*
+http.ResponseWriter, which remembers written status and counts body bytes (for logging, metrics or Content-Length of buffered response)
+*/
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *countingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= 200 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *countingResponseWriter) Flush() {
+	if w.status == 0 {
+		// flush sends headers with implicit 200 status
+		w.status = http.StatusOK
+	}
+	flushResponse(w.ResponseWriter)
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*
+Get written status (200 if handler did not write anything)
+*/
+func (w *countingResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+/*
+Get number of body bytes written to underlying http.ResponseWriter
+*/
+func (w *countingResponseWriter) BytesWritten() int64 {
+	return w.bytes
+}
+
+/*
+Check if final status or body is already written
+*/
+func (w *countingResponseWriter) Written() bool {
+	return w.status != 0
+}
@@ -0,0 +1,73 @@
+package webimizer
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCountingResponseWriter(t *testing.T) {
+	// httptest.ResponseRecorder treats informational status as final one
+	rec := &informationalRecorder{ResponseRecorder: httptest.NewRecorder()}
+	w := &countingResponseWriter{ResponseWriter: rec}
+	if w.Written() || w.Status() != http.StatusOK || w.BytesWritten() != 0 {
+		t.Fatalf("new writer: written %v status %d bytes %d", w.Written(), w.Status(), w.BytesWritten())
+	}
+	w.WriteHeader(http.StatusContinue)
+	if w.Written() {
+		t.Error("informational status is counted as written")
+	}
+	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusInternalServerError)
+	for _, chunk := range []string{"hello", ", ", "world"} {
+		w.Write([]byte(chunk))
+	}
+	if !w.Written() || w.Status() != http.StatusAccepted {
+		t.Errorf("written %v status %d, want first final status 202", w.Written(), w.Status())
+	}
+	if len(rec.statuses) != 3 || rec.Code != http.StatusAccepted {
+		t.Errorf("statuses %v, want all statuses passed to underlying writer", rec.statuses)
+	}
+	if w.BytesWritten() != int64(len("hello, world")) || rec.Body.String() != "hello, world" {
+		t.Errorf("bytes = %d body %q, want 12 hello, world", w.BytesWritten(), rec.Body.String())
+	}
+
+	w = &countingResponseWriter{ResponseWriter: httptest.NewRecorder()}
+	w.Write([]byte("implicit"))
+	if w.Status() != http.StatusOK || w.BytesWritten() != 8 || !w.Written() {
+		t.Errorf("implicit status: written %v status %d bytes %d", w.Written(), w.Status(), w.BytesWritten())
+	}
+	if w.Unwrap() == nil {
+		t.Error("Unwrap returns nil")
+	}
+}
+
+func TestCountingResponseWriterFlush(t *testing.T) {
+	streaming := func(rw http.ResponseWriter, r *http.Request) {
+		flusher, ok := rw.(http.Flusher)
+		if !ok {
+			t.Errorf("%s: %T does not implement http.Flusher", r.URL.Path, rw)
+			return
+		}
+		io.WriteString(rw, "data: event\n\n")
+		flusher.Flush()
+	}
+	handlers := map[string]http.Handler{
+		"/slog":     HttpHandler(streaming).WithSlog(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		"/recovery": HttpHandler(streaming).WithRecoveryJSON(),
+		"/recorder": HttpHandler(streaming).WithRequestRecorder(NewRequestRecorder(1)),
+		"/after": HttpHandlerStruct{
+			AllowedMethods: []string{http.MethodGet},
+			Handler:        streaming,
+			AfterResponse:  func(r *http.Request, status int, bytes int, err error) {},
+		}.Build(),
+	}
+	for path, handler := range handlers {
+		rec := serveRequest(handler, http.MethodGet, path, nil)
+		if !rec.Flushed || rec.Body.String() != "data: event\n\n" {
+			t.Errorf("%s: flushed %v body %q, want flushed event", path, rec.Flushed, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package webimizer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+type cspNonceKey struct{}
+
+/*
+Generate random nonce for each request, add 'nonce-<nonce>' source to script-src directive of Content-Security-Policy header and store nonce in request context (use CSPNonce func in templates: <script nonce="{{.Nonce}}">). If policy is empty, Content-Security-Policy header already set on response (for example, by DefaultHTTPHeaders) is used. If policy has no script-src directive, it is created from default-src sources (or with nonce only)
+Example:
+
+	handler.WithCSPNonce("default-src 'self'; script-src 'self' 'strict-dynamic'")
+*/
+func (fn HttpHandler) WithCSPNonce(policy string) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 16)
+		rand.Read(b)
+		nonce := base64.StdEncoding.EncodeToString(b)
+		current := policy
+		if current == "" {
+			current = rw.Header().Get("Content-Security-Policy")
+		}
+		rw.Header().Set("Content-Security-Policy", addCSPNonce(current, nonce))
+		fn(rw, r.WithContext(context.WithValue(r.Context(), cspNonceKey{}, nonce)))
+	})
+}
+
+/*
+Get CSP nonce of request (set by WithCSPNonce)
+*/
+func CSPNonce(r *http.Request) string {
+	nonce, _ := r.Context().Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+/*
+Add nonce source to script-src directive of policy
+*/
+func addCSPNonce(policy, nonce string) string {
+	source := "'nonce-" + nonce + "'"
+	var directives []string
+	defaultSources := ""
+	found := false
+	for _, directive := range strings.Split(policy, ";") {
+		directive = strings.TrimSpace(directive)
+		if directive == "" {
+			continue
+		}
+		fields := strings.Fields(directive)
+		switch strings.ToLower(fields[0]) {
+		case "script-src":
+			directive += " " + source
+			found = true
+		case "default-src":
+			defaultSources = strings.Join(fields[1:], " ")
+		}
+		directives = append(directives, directive)
+	}
+	if !found {
+		scriptSrc := "script-src"
+		// 'none' can not be combined with other sources
+		if defaultSources != "" && defaultSources != "'none'" {
+			scriptSrc += " " + defaultSources
+		}
+		directives = append(directives, scriptSrc+" "+source)
+	}
+	return strings.Join(directives, "; ")
+}
@@ -0,0 +1,47 @@
+package webimizer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestWithCSPNonce(t *testing.T) {
+	var nonces []string
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, CSPNonce(r))
+	}).WithCSPNonce("default-src 'self'; script-src 'self' 'strict-dynamic'")
+	var policies []string
+	for i := 0; i < 2; i++ {
+		rec := serveRequest(handler, http.MethodGet, "/", nil)
+		policies = append(policies, rec.Header().Get("Content-Security-Policy"))
+	}
+	for i, nonce := range nonces {
+		if nonce == "" {
+			t.Fatal("nonce is not stored in request context")
+		}
+		want := "default-src 'self'; script-src 'self' 'strict-dynamic' 'nonce-" + nonce + "'"
+		if policies[i] != want {
+			t.Errorf("Content-Security-Policy %q, want %q", policies[i], want)
+		}
+	}
+	if nonces[0] == nonces[1] {
+		t.Error("same nonce is used for two requests")
+	}
+}
+
+func TestAddCSPNonce(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{"default-src 'self' https://cdn.example.com", "default-src 'self' https://cdn.example.com; script-src 'self' https://cdn.example.com 'nonce-abc'"},
+		{"default-src 'none'; img-src 'self'", "default-src 'none'; img-src 'self'; script-src 'nonce-abc'"},
+		{"", "script-src 'nonce-abc'"},
+		{"script-src 'self'", "script-src 'self' 'nonce-abc'"},
+	}
+	for _, test := range tests {
+		if got := addCSPNonce(test.policy, "abc"); got != test.want {
+			t.Errorf("addCSPNonce(%q) = %q, want %q", test.policy, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package webimizer
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	// Cookie, which contains CSRF token
+	CSRFCookieName = "csrf_token"
+	// Request header, which can contain CSRF token
+	CSRFHeaderName = "X-CSRF-Token"
+	// Form field, which can contain CSRF token
+	CSRFFormField = "csrf_token"
+)
+
+type csrfTokenKey struct{}
+
+/*
+Protect from CSRF attacks: CSRF token (random value signed by HMAC-SHA256 with secret) is set in csrf_token cookie and POST, PUT, DELETE and PATCH requests must send the same token in X-CSRF-Token header or csrf_token form field, otherwise 403 Forbidden status is returned. Use CSRFToken func to get token for forms
+*/
+func (fn HttpHandler) WithCSRF(secret []byte) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		token := ""
+		if cookie, err := r.Cookie(CSRFCookieName); err == nil && validCSRFToken(cookie.Value, secret) {
+			token = cookie.Value
+		}
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
+			sent := r.Header.Get(CSRFHeaderName)
+			if sent == "" {
+				sent = r.PostFormValue(CSRFFormField)
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		if token == "" {
+			token = newCSRFToken(secret)
+			http.SetCookie(rw, &http.Cookie{
+				Name:     CSRFCookieName,
+				Value:    token,
+				Path:     "/",
+				Secure:   RequestScheme(r) == "https",
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+		fn(rw, r.WithContext(context.WithValue(r.Context(), csrfTokenKey{}, token)))
+	})
+}
+
+/*
+Get CSRF token of request (set by WithCSRF) for use in forms or templates
+*/
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfTokenKey{}).(string)
+	return token
+}
+
+func newCSRFToken(secret []byte) string {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	value := hex.EncodeToString(nonce)
+	return value + "." + csrfSignature(value, secret)
+}
+
+func csrfSignature(value string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func validCSRFToken(token string, secret []byte) bool {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(csrfSignature(parts[0], secret)))
+}
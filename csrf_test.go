@@ -0,0 +1,70 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestWithCSRF(t *testing.T) {
+	secret := []byte("test secret")
+	var seenToken string
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		seenToken = CSRFToken(r)
+		okHandler(rw, r)
+	}).WithCSRF(secret)
+
+	// safe method gets token cookie
+	rec := serveRequest(handler, http.MethodGet, "/form", nil)
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != CSRFCookieName {
+		t.Fatalf("GET got %d with cookies %v, want 200 with %s cookie", rec.Code, cookies, CSRFCookieName)
+	}
+	token := cookies[0].Value
+	if seenToken != token {
+		t.Errorf("CSRFToken() = %q, want cookie value %q", seenToken, token)
+	}
+
+	send := func(method, headerToken, formToken, cookieToken string) int {
+		var r *http.Request
+		if formToken != "" {
+			r = httptest.NewRequest(method, "/form", strings.NewReader(url.Values{CSRFFormField: {formToken}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			r = httptest.NewRequest(method, "/form", nil)
+		}
+		if headerToken != "" {
+			r.Header.Set(CSRFHeaderName, headerToken)
+		}
+		if cookieToken != "" {
+			r.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: cookieToken})
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+		return rec.Code
+	}
+	forged := newCSRFToken([]byte("other secret"))
+	tests := []struct {
+		name                                string
+		method                              string
+		headerToken, formToken, cookieToken string
+		want                                int
+	}{
+		{"valid header token", http.MethodPost, token, "", token, http.StatusOK},
+		{"valid form token", http.MethodPut, "", token, token, http.StatusOK},
+		{"missing token", http.MethodPost, "", "", token, http.StatusForbidden},
+		{"missing cookie", http.MethodDelete, token, "", "", http.StatusForbidden},
+		{"wrong token", http.MethodPatch, newCSRFToken(secret), "", token, http.StatusForbidden},
+		{"forged cookie", http.MethodPost, forged, "", forged, http.StatusForbidden},
+		{"safe GET without token", http.MethodGet, "", "", "", http.StatusOK},
+		{"safe HEAD without token", http.MethodHead, "", "", token, http.StatusOK},
+		{"safe OPTIONS without token", http.MethodOptions, "", "", "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if got := send(tt.method, tt.headerToken, tt.formToken, tt.cookieToken); got != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
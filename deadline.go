@@ -0,0 +1,113 @@
+package webimizer
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Apply client timeout budget from X-Request-Timeout request header (Go duration like "2s" or number of seconds) as request context deadline. Timeout is capped to max (if max > 0), so max is also used when header is not set or invalid.
+If handler does not finish before deadline, 504 Gateway Timeout status is returned. Response is buffered until handler finishes
+*/
+func (fn HttpHandler) WithClientDeadline(max time.Duration) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		timeout := max
+		if d, ok := parseRequestTimeout(r.Header.Get("X-Request-Timeout")); ok && (max <= 0 || d < max) {
+			timeout = d
+		}
+		if timeout <= 0 {
+			fn(rw, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		tw := &timeoutWriter{header: make(http.Header), buf: getBuffer()}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			fn(tw, r.WithContext(ctx))
+			close(done)
+		}()
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			dst := rw.Header()
+			for k, v := range tw.header {
+				dst[k] = v
+			}
+			if tw.status != 0 {
+				rw.WriteHeader(tw.status)
+			}
+			rw.Write(tw.buf.Bytes())
+			// handler goroutine is finished, so buffer can be reused
+			putBuffer(tw.buf)
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+			tw.timedOut = true
+			if ctx.Err() == context.DeadlineExceeded {
+				http.Error(rw, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+			}
+		}
+	})
+}
+
+func parseRequestTimeout(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d, d > 0
+	}
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+/*
+http.ResponseWriter, which buffers response of handler running in separate goroutine
+*/
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      *bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	// informational response can not be sent from handler goroutine, so it is discarded
+	if tw.timedOut || tw.status != 0 || status < 200 {
+		return
+	}
+	tw.status = status
+}
@@ -0,0 +1,76 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+/*
+Handler, which works for d (or until request context is done) and reports request context deadline
+*/
+func slowHandler(d time.Duration, deadlines chan<- time.Time) HttpHandler {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		deadline, _ := r.Context().Deadline()
+		deadlines <- deadline
+		select {
+		case <-time.After(d):
+			rw.WriteHeader(http.StatusCreated)
+			io.WriteString(rw, "done")
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func serveWithRequestTimeout(handler HttpHandler, timeout string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if timeout != "" {
+		r.Header.Set("X-Request-Timeout", timeout)
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+func TestWithClientDeadlineShorterThanHandler(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	handler := slowHandler(5*time.Second, deadlines).WithClientDeadline(time.Minute)
+	start := time.Now()
+	rec := serveWithRequestTimeout(handler, "50ms")
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("response took %v, want about client deadline", elapsed)
+	}
+	if deadline := <-deadlines; deadline.Sub(start) > time.Second {
+		t.Errorf("handler deadline is %v after start, want client deadline", deadline.Sub(start))
+	}
+}
+
+func TestWithClientDeadlineOverMax(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	handler := slowHandler(5*time.Second, deadlines).WithClientDeadline(50 * time.Millisecond)
+	start := time.Now()
+	rec := serveWithRequestTimeout(handler, "1h")
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", rec.Code)
+	}
+	if deadline := <-deadlines; deadline.Sub(start) > time.Second {
+		t.Errorf("handler deadline is %v after start, want server max", deadline.Sub(start))
+	}
+}
+
+func TestWithClientDeadlineInTime(t *testing.T) {
+	deadlines := make(chan time.Time, 1)
+	handler := slowHandler(time.Millisecond, deadlines).WithClientDeadline(time.Minute)
+	for _, timeout := range []string{"10s", "10", "invalid", ""} {
+		rec := serveWithRequestTimeout(handler, timeout)
+		<-deadlines
+		if rec.Code != http.StatusCreated || rec.Body.String() != "done" {
+			t.Errorf("X-Request-Timeout %q got %d %q, want 201 done", timeout, rec.Code, rec.Body.String())
+		}
+	}
+}
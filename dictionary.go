@@ -0,0 +1,35 @@
+package webimizer
+
+import (
+	"compress/flate"
+	"net/http"
+)
+
+/*
+Content-Encoding of responses compressed by WithCompressionDictionary: raw DEFLATE stream (RFC 1951) with preset dictionary
+*/
+const DictionaryEncoding = "x-deflate-dict"
+
+/*
+Compress response with DEFLATE using preset dictionary dict (for example, typical JSON object of API), which dramatically improves compression ratio of small repetitive responses. Standard gzip and deflate decoders (browsers) can not decode such response, so it is used only if client lists DictionaryEncoding in Accept-Encoding request header, other clients get response compressed by ServeHTTP as usual. Client must decode response with the same dictionary (for example, flate.NewReaderDict in Go). GzipCompressionLevel, GzipMinLength and other gzip options are applied too
+Example:
+
+	handler.WithCompressionDictionary([]byte(`{"id":0,"name":"","created_at":"","tags":[]}`))
+*/
+func (fn HttpHandler) WithCompressionDictionary(dict []byte) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if acceptEncodingQuality(r.Header.Get("Accept-Encoding"), DictionaryEncoding) <= 0 || alreadyEncoded(rw.Header()) {
+			fn(rw, r)
+			return
+		}
+		w := &gzipResponseWriter{ResponseWriter: rw, encoding: DictionaryEncoding}
+		fw, err := flate.NewWriterDict(countingWriter{w: rw, n: &w.compressed}, GzipCompressionLevel, dict)
+		if err != nil {
+			fn(rw, r)
+			return
+		}
+		w.enc = fw
+		defer w.Close()
+		fn(w, r)
+	})
+}
@@ -0,0 +1,43 @@
+package webimizer
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestWithCompressionDictionary(t *testing.T) {
+	dict := []byte(`{"id":0,"name":"","created_at":"","tags":[]}`)
+	content := `[{"id":1,"name":"webimizer","created_at":"2024-03-10T12:00:00Z","tags":["go","http"]},` +
+		`{"id":2,"name":"gzip","created_at":"2024-03-11T08:30:00Z","tags":["compression"]},` +
+		`{"id":3,"name":"brotli","created_at":"2024-03-12T16:45:00Z","tags":["compression","http"]}]`
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		io.WriteString(rw, content)
+	}).WithCompressionDictionary(dict)
+
+	rec := serveRequest(handler, http.MethodGet, "/item", http.Header{"Accept-Encoding": {"gzip, " + DictionaryEncoding}})
+	if got := rec.Header().Values("Content-Encoding"); len(got) != 1 || got[0] != DictionaryEncoding {
+		t.Fatalf("Content-Encoding = %q, want %s", got, DictionaryEncoding)
+	}
+	body, err := io.ReadAll(flate.NewReaderDict(bytes.NewReader(rec.Body.Bytes()), dict))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != content {
+		t.Errorf("decoded body = %q, want %q", body, content)
+	}
+	if body, err := io.ReadAll(flate.NewReader(bytes.NewReader(rec.Body.Bytes()))); err == nil && string(body) == content {
+		t.Error("body is compressed without dictionary")
+	}
+
+	gzipped := serveRequest(handler, http.MethodGet, "/item", http.Header{"Accept-Encoding": {"gzip"}})
+	if gzipped.Header().Get("Content-Encoding") != "gzip" || gunzip(t, gzipped.Body.Bytes()) != content {
+		t.Errorf("client without dictionary support got Content-Encoding %q, want gzip", gzipped.Header().Get("Content-Encoding"))
+	}
+	if rec.Body.Len() >= gzipped.Body.Len() {
+		t.Errorf("dictionary compressed body is %d bytes, gzip body is %d bytes", rec.Body.Len(), gzipped.Body.Len())
+	}
+}
@@ -0,0 +1,52 @@
+package webimizer
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+/*
+Send 103 Early Hints informational response with Link headers (for example, "</app.css>; rel=preload; as=style"), so client can start loading critical resources before main response is ready. Must be called before final status or body is written. Link headers stay in response header map, so they are sent with final response too (RFC 8297). If links is empty, nothing is sent.
+Informational response is sent only if w is (or wraps) net/http server connection, otherwise only Link headers are set (for example, for httptest.ResponseRecorder, which would treat 103 as final status). HTTP/1.0 clients do not expect informational responses, so call it only if r.ProtoAtLeast(1, 1) is true
+Example:
+
+	if r.ProtoAtLeast(1, 1) {
+		app.SendEarlyHints(rw, []string{"</app.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"})
+	}
+*/
+func SendEarlyHints(w http.ResponseWriter, links []string) error {
+	if len(links) == 0 {
+		return nil
+	}
+	for _, link := range links {
+		if strings.TrimSpace(link) == "" || strings.ContainsAny(link, "\r\n") {
+			return errors.New("webimizer: invalid Link header value: " + link)
+		}
+	}
+	h := w.Header()
+	for _, link := range links {
+		h.Add("Link", link)
+	}
+	if !informationalSupported(w) {
+		return nil
+	}
+	w.WriteHeader(http.StatusEarlyHints)
+	return nil
+}
+
+/*
+Check if w is (or wraps) net/http server connection, which can send informational response before final one (HTTP/1.x connection implements http.Hijacker, HTTP/2 stream implements http.Pusher). Connection state is not changed
+*/
+func informationalSupported(w http.ResponseWriter) bool {
+	for {
+		switch t := w.(type) {
+		case http.Hijacker, http.Pusher:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package webimizer
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"reflect"
+	"testing"
+)
+
+/*
+http.ResponseWriter, which records all WriteHeader calls with Link headers sent at that time (like net/http server connection, it supports informational responses and implements http.Hijacker)
+*/
+type informationalRecorder struct {
+	*httptest.ResponseRecorder
+	statuses []int
+	links    [][]string
+}
+
+func (w *informationalRecorder) WriteHeader(status int) {
+	w.statuses = append(w.statuses, status)
+	w.links = append(w.links, append([]string(nil), w.Header().Values("Link")...))
+	if status >= 200 {
+		w.ResponseRecorder.WriteHeader(status)
+	}
+}
+
+func (w *informationalRecorder) Write(b []byte) (int, error) {
+	if !w.wroteHeader() {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *informationalRecorder) wroteHeader() bool {
+	return len(w.statuses) > 0 && w.statuses[len(w.statuses)-1] >= 200
+}
+
+func (w *informationalRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, http.ErrNotSupported
+}
+
+var earlyHintLinks = []string{"</app.css>; rel=preload; as=style", "</app.js>; rel=preload; as=script"}
+
+func TestSendEarlyHints(t *testing.T) {
+	w := &informationalRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := SendEarlyHints(w, earlyHintLinks); err != nil {
+		t.Fatal(err)
+	}
+	w.WriteHeader(http.StatusOK)
+	if !reflect.DeepEqual(w.statuses, []int{http.StatusEarlyHints, http.StatusOK}) {
+		t.Fatalf("statuses = %v, want [103 200]", w.statuses)
+	}
+	for i, links := range w.links {
+		if !reflect.DeepEqual(links, earlyHintLinks) {
+			t.Errorf("response %d Link headers = %v, want %v", w.statuses[i], links, earlyHintLinks)
+		}
+	}
+}
+
+func TestSendEarlyHintsThroughServeHTTP(t *testing.T) {
+	w := &informationalRecorder{ResponseRecorder: httptest.NewRecorder()}
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		SendEarlyHints(rw, earlyHintLinks)
+		rw.Write([]byte("page"))
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(w, r)
+	if !reflect.DeepEqual(w.statuses, []int{http.StatusEarlyHints, http.StatusOK}) {
+		t.Errorf("statuses = %v, want [103 200]", w.statuses)
+	}
+}
+
+func TestSendEarlyHintsUnsupported(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if err := SendEarlyHints(rec, earlyHintLinks); err != nil {
+		t.Fatal(err)
+	}
+	rec.WriteHeader(http.StatusCreated)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201 (103 must not be sent to writer without informational response support)", rec.Code)
+	}
+	if got := rec.Header().Values("Link"); !reflect.DeepEqual(got, earlyHintLinks) {
+		t.Errorf("Link headers = %v, want %v", got, earlyHintLinks)
+	}
+}
+
+func TestSendEarlyHintsServer(t *testing.T) {
+	server := httptest.NewServer(HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.ProtoAtLeast(1, 1) {
+			SendEarlyHints(rw, earlyHintLinks)
+		}
+		rw.Write([]byte("page"))
+	}))
+	defer server.Close()
+	var hints [][]string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code == http.StatusEarlyHints {
+				hints = append(hints, header.Values("Link"))
+			}
+			return nil
+		},
+	}
+	req, _ := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, server.URL, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if len(hints) != 1 || !reflect.DeepEqual(hints[0], earlyHintLinks) {
+		t.Errorf("early hints %v, want one with %v", hints, earlyHintLinks)
+	}
+	if !reflect.DeepEqual(res.Header.Values("Link"), earlyHintLinks) {
+		t.Errorf("final Link headers %v, want %v", res.Header.Values("Link"), earlyHintLinks)
+	}
+}
+
+func TestSendEarlyHintsInvalidLink(t *testing.T) {
+	w := &informationalRecorder{ResponseRecorder: httptest.NewRecorder()}
+	if err := SendEarlyHints(w, []string{"</a.css>\r\nX-Injected: 1"}); err == nil {
+		t.Error("invalid Link header value is accepted")
+	}
+	if len(w.statuses) != 0 || w.Header().Get("Link") != "" {
+		t.Error("invalid Link header is sent")
+	}
+}
@@ -0,0 +1,59 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+Request information returned by EchoHandler
+*/
+type EchoInfo struct {
+	ClientIP         string              `json:"client_ip"`
+	RemoteAddr       string              `json:"remote_addr"`
+	Scheme           string              `json:"scheme"`
+	Host             string              `json:"host"`
+	Method           string              `json:"method"`
+	Path             string              `json:"path"`
+	Origin           string              `json:"origin,omitempty"`
+	AcceptEncoding   string              `json:"accept_encoding,omitempty"`
+	Encoding         string              `json:"encoding"`
+	ForwardedHeaders map[string][]string `json:"forwarded_headers"`
+}
+
+// Request headers set by reverse proxies, which are returned by EchoHandler
+var echoForwardedHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Forwarded-Proto", "X-Forwarded-Host", "X-Real-Ip"}
+
+/*
+Create HttpHandler, which returns resolved client IP (ClientIP), scheme (RequestScheme), raw forwarding headers, negotiated response encoding and Origin header (it is matched already, if HttpHandlerStruct.AllowedOrigins is set) as JSON. Use it for debugging of deployment behind proxies (TrustedProxies), do not expose it in production
+Example:
+
+	http.Handle("/debug/echo", app.EchoHandler())
+*/
+func EchoHandler() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		info := EchoInfo{
+			ClientIP:         ClientIP(r),
+			RemoteAddr:       r.RemoteAddr,
+			Scheme:           RequestScheme(r),
+			Host:             r.Host,
+			Method:           r.Method,
+			Path:             r.URL.Path,
+			Origin:           r.Header.Get("Origin"),
+			AcceptEncoding:   r.Header.Get("Accept-Encoding"),
+			Encoding:         "identity",
+			ForwardedHeaders: map[string][]string{},
+		}
+		if GzipActive(r) {
+			info.Encoding = "gzip"
+		}
+		for _, name := range echoForwardedHeaders {
+			if values := r.Header.Values(name); len(values) > 0 {
+				info.ForwardedHeaders[name] = values
+			}
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(rw).Encode(info)
+	})
+}
@@ -0,0 +1,57 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestEchoHandler(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8")
+	r := newForwardedRequest("10.0.0.1:4321", http.Header{
+		"X-Forwarded-For":   {"203.0.113.7"},
+		"X-Forwarded-Proto": {"https"},
+		"Origin":            {"https://app.example.com"},
+		"Accept-Encoding":   {"gzip"},
+	})
+	r.Method = http.MethodPost
+	r.URL.Path = "/debug/echo"
+	rec := httptest.NewRecorder()
+	EchoHandler().ServeHTTP(rec, r)
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Content-Type %q, Cache-Control %q", rec.Header().Get("Content-Type"), rec.Header().Get("Cache-Control"))
+	}
+	var info EchoInfo
+	if err := json.Unmarshal([]byte(gunzip(t, rec.Body.Bytes())), &info); err != nil {
+		t.Fatal(err)
+	}
+	want := EchoInfo{
+		ClientIP:       "203.0.113.7",
+		RemoteAddr:     "10.0.0.1:4321",
+		Scheme:         "https",
+		Host:           "example.com",
+		Method:         http.MethodPost,
+		Path:           "/debug/echo",
+		Origin:         "https://app.example.com",
+		AcceptEncoding: "gzip",
+		Encoding:       "gzip",
+		ForwardedHeaders: map[string][]string{
+			"X-Forwarded-For":   {"203.0.113.7"},
+			"X-Forwarded-Proto": {"https"},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("echo info = %+v, want %+v", info, want)
+	}
+
+	rec = serveRequest(EchoHandler(), http.MethodGet, "/debug/echo", nil)
+	info = EchoInfo{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Encoding != "identity" || info.Scheme != "http" || len(info.ForwardedHeaders) != 0 {
+		t.Errorf("direct request echo info = %+v", info)
+	}
+}
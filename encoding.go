@@ -0,0 +1,128 @@
+package webimizer
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Max count of distinct Accept-Encoding header values in parse cache (cache is cleared, when it is full)
+const acceptEncodingCacheSize = 256
+
+// Max length of Accept-Encoding header value, which is cached (longer values are parsed every time, so clients can not fill cache with huge values)
+const acceptEncodingCacheMaxLen = 128
+
+var (
+	acceptEncodingCacheMu sync.RWMutex
+	acceptEncodingCache   = map[string][]Encoding{}
+)
+
+/*
+Content coding of Accept-Encoding request header with quality value
+*/
+type Encoding struct {
+	Coding  string
+	Quality float64
+}
+
+/*
+Parse Accept-Encoding request header to encodings (coding names are lowercase) ordered by quality value (the most preferred first, encodings with the same quality keep header order). Items with empty coding name or invalid quality value are skipped, quality value of 0 means "not acceptable"
+Example:
+
+	app.ParseAcceptEncoding("gzip;q=0.8, br, identity;q=0") // [{br 1} {gzip 0.8} {identity 0}]
+*/
+func ParseAcceptEncoding(header string) []Encoding {
+	var encodings []Encoding
+	for _, item := range strings.Split(header, ",") {
+		params := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q, ok := parseQValue(params[1:])
+		if !ok {
+			continue
+		}
+		encodings = append(encodings, Encoding{Coding: coding, Quality: q})
+	}
+	sort.SliceStable(encodings, func(i, j int) bool { return encodings[i].Quality > encodings[j].Quality })
+	return encodings
+}
+
+/*
+Get parsed Accept-Encoding request header from cache (keyed by exact header value, because clients send the same value on every request). Returned slice is shared, so it must not be modified
+*/
+func cachedAcceptEncoding(header string) []Encoding {
+	acceptEncodingCacheMu.RLock()
+	encodings, ok := acceptEncodingCache[header]
+	acceptEncodingCacheMu.RUnlock()
+	if ok {
+		return encodings
+	}
+	encodings = ParseAcceptEncoding(header)
+	if len(header) > acceptEncodingCacheMaxLen {
+		return encodings
+	}
+	acceptEncodingCacheMu.Lock()
+	if len(acceptEncodingCache) >= acceptEncodingCacheSize {
+		acceptEncodingCache = map[string][]Encoding{}
+	}
+	acceptEncodingCache[header] = encodings
+	acceptEncodingCacheMu.Unlock()
+	return encodings
+}
+
+/*
+Get quality value from header item parameters (1 if q parameter is not set, false if it is invalid)
+*/
+func parseQValue(params []string) (float64, bool) {
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if len(param) < 2 || !strings.EqualFold(param[:2], "q=") {
+			continue
+		}
+		q, err := strconv.ParseFloat(param[2:], 64)
+		// NaN is not in range too
+		if err != nil || !(q >= 0 && q <= 1) {
+			return 0, false
+		}
+		return q, true
+	}
+	return 1, true
+}
+
+/*
+Get quality value of coding in Accept-Encoding request header (0 if coding is not accepted). Wildcard "*" is used only if coding is not listed explicitly
+*/
+func acceptEncodingQuality(header, coding string) float64 {
+	q, _ := encodingQuality(header, coding)
+	return q
+}
+
+/*
+Get quality value of coding in Accept-Encoding request header and true if coding is listed explicitly or by wildcard "*"
+*/
+func encodingQuality(header, coding string) (float64, bool) {
+	wildcard := -1.0
+	for _, encoding := range cachedAcceptEncoding(header) {
+		if encoding.Coding == coding {
+			return encoding.Quality, true
+		}
+		if encoding.Coding == "*" && wildcard < 0 {
+			wildcard = encoding.Quality
+		}
+	}
+	if wildcard >= 0 {
+		return wildcard, true
+	}
+	return 0, false
+}
+
+/*
+Check if Accept-Encoding request header forbids uncompressed response (for example, "identity;q=0" or "*;q=0")
+*/
+func identityForbidden(header string) bool {
+	q, listed := encodingQuality(header, "identity")
+	return listed && q <= 0
+}
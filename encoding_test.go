@@ -0,0 +1,74 @@
+package webimizer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAcceptEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []Encoding
+	}{
+		{"", nil},
+		{"gzip", []Encoding{{"gzip", 1}}},
+		{"gzip;q=0.8, br, identity;q=0", []Encoding{{"br", 1}, {"gzip", 0.8}, {"identity", 0}}},
+		{"GZIP ; Q=0.5 ,  Br;q=1.0", []Encoding{{"br", 1}, {"gzip", 0.5}}},
+		{"deflate, gzip, br", []Encoding{{"deflate", 1}, {"gzip", 1}, {"br", 1}}},
+		{"gzip;level=9;q=0.3, *;q=0.1", []Encoding{{"gzip", 0.3}, {"*", 0.1}}},
+		// malformed items are skipped
+		{",, ;q=1, gzip", []Encoding{{"gzip", 1}}},
+		{"gzip;q=abc, br", []Encoding{{"br", 1}}},
+		{"gzip;q=1.5, br;q=-0.1, deflate;q=NaN, zstd;q=", nil},
+		{"gzip;q", []Encoding{{"gzip", 1}}},
+	}
+	for _, test := range tests {
+		if got := ParseAcceptEncoding(test.header); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseAcceptEncoding(%q) = %v, want %v", test.header, got, test.want)
+		}
+	}
+}
+
+func TestCachedAcceptEncoding(t *testing.T) {
+	header := "gzip;q=0.8, br"
+	want := ParseAcceptEncoding(header)
+	for i := 0; i < 2; i++ {
+		if got := cachedAcceptEncoding(header); !reflect.DeepEqual(got, want) {
+			t.Fatalf("cachedAcceptEncoding() = %v, want %v", got, want)
+		}
+	}
+	if got := acceptEncodingQuality(header, "gzip"); got != 0.8 {
+		t.Errorf("acceptEncodingQuality(gzip) = %v, want 0.8", got)
+	}
+}
+
+func TestCachedAcceptEncodingSkipsLongValues(t *testing.T) {
+	header := "gzip, " + strings.Repeat("x", acceptEncodingCacheMaxLen)
+	cachedAcceptEncoding(header)
+	acceptEncodingCacheMu.RLock()
+	_, cached := acceptEncodingCache[header]
+	acceptEncodingCacheMu.RUnlock()
+	if cached {
+		t.Error("long Accept-Encoding value is cached")
+	}
+	if got := acceptEncodingQuality(header, "gzip"); got != 1 {
+		t.Errorf("acceptEncodingQuality(gzip) = %v, want 1", got)
+	}
+}
+
+const benchmarkAcceptEncoding = "gzip;q=0.8, deflate, br;q=1.0, identity;q=0.5, *;q=0"
+
+func BenchmarkAcceptEncodingParse(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseAcceptEncoding(benchmarkAcceptEncoding)
+	}
+}
+
+func BenchmarkAcceptEncodingCached(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		acceptEncodingQuality(benchmarkAcceptEncoding, "gzip")
+	}
+}
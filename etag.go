@@ -0,0 +1,62 @@
+package webimizer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+)
+
+/*
+Cache of content hash ETags by file path
+*/
+type etagCache struct {
+	mu    sync.RWMutex
+	etags map[string]string
+}
+
+/*
+Get strong ETag from content hash
+*/
+func contentHashETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+/*
+Set ETag header from content hash, if file modification time is unknown (in-memory and embedded filesystems), so conditional requests work without Last-Modified. File content of such filesystems does not change, so ETag is computed only once per path. Files of memoryFileSystem have precomputed ETag. Content is not read for HEAD requests, so ETag is set only if it is already computed by GET request
+*/
+func (nfs neuteredFileSystem) setContentETag(path string, f http.File) {
+	if nfs.etags == nil || nfs.w.Header().Get("ETag") != "" {
+		return
+	}
+	s, err := f.Stat()
+	if err != nil || !s.ModTime().IsZero() {
+		return
+	}
+	if mf, ok := f.(interface{ contentETag() string }); ok && mf.contentETag() != "" {
+		nfs.w.Header().Set("ETag", mf.contentETag())
+		return
+	}
+	nfs.etags.mu.RLock()
+	etag, ok := nfs.etags.etags[path]
+	nfs.etags.mu.RUnlock()
+	if !ok && nfs.r.Method == http.MethodHead {
+		return
+	}
+	if !ok {
+		content, err := io.ReadAll(f)
+		if err != nil {
+			return
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return
+		}
+		etag = contentHashETag(content)
+		nfs.etags.mu.Lock()
+		nfs.etags.etags[path] = etag
+		nfs.etags.mu.Unlock()
+	}
+	nfs.w.Header().Set("ETag", etag)
+}
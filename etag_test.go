@@ -0,0 +1,61 @@
+package webimizer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestContentHashETag(t *testing.T) {
+	icon := []byte("\x00\x00\x01\x00icon")
+	rec := serveRequest(FaviconHandler(icon), http.MethodGet, "/favicon.ico", nil)
+	if got := rec.Header().Get("ETag"); got != contentHashETag(icon) {
+		t.Errorf("favicon ETag = %q, want %q", got, contentHashETag(icon))
+	}
+
+	body := []byte(`{"status":"ok"}`)
+	responses := NewPrecomputedResponseHandler()
+	if err := responses.Register("/status", http.StatusOK, "application/json", body); err != nil {
+		t.Fatal(err)
+	}
+	rec = serveRequest(responses.Handler(), http.MethodGet, "/status", nil)
+	if got := rec.Header().Get("ETag"); got != contentHashETag(body) {
+		t.Errorf("precomputed ETag = %q, want %q", got, contentHashETag(body))
+	}
+	rec = serveRequest(responses.Handler(), http.MethodGet, "/status", http.Header{"Accept-Encoding": {"gzip"}})
+	if want := strings.TrimSuffix(contentHashETag(body), `"`) + `-gzip"`; rec.Header().Get("ETag") != want {
+		t.Errorf("compressed precomputed ETag = %q, want %q", rec.Header().Get("ETag"), want)
+	}
+
+	// files of fstest.MapFS have no modification time, so ETag is computed from content
+	content := []byte("body { color: red }")
+	handler := FileServerStruct{FileSystem: http.FS(fstest.MapFS{"app.css": {Data: content}})}.Build()
+	rec = serveRequest(handler, http.MethodGet, "/app.css", nil)
+	if got := rec.Header().Get("ETag"); got != contentHashETag(content) {
+		t.Errorf("file server ETag = %q, want %q", got, contentHashETag(content))
+	}
+	rec = serveRequest(handler, http.MethodHead, "/app.css", nil)
+	if got := rec.Header().Get("ETag"); got != contentHashETag(content) {
+		t.Errorf("file server HEAD after GET ETag = %q, want %q", got, contentHashETag(content))
+	}
+}
+
+func TestContentHashETagCompressed(t *testing.T) {
+	content := []byte(strings.Repeat("body { color: red }\n", 100))
+	handler := FileServerStruct{FileSystem: http.FS(fstest.MapFS{"app.css": {Data: content}})}.Build()
+	gzipHeader := http.Header{"Accept-Encoding": {"gzip"}}
+	weak := "W/" + contentHashETag(content)
+	rec := serveRequest(handler, http.MethodGet, "/app.css", gzipHeader)
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("ETag") != weak {
+		t.Errorf("gzip GET got %q ETag %q, want gzip ETag %q", rec.Header().Get("Content-Encoding"), rec.Header().Get("ETag"), weak)
+	}
+	rec = serveRequest(handler, http.MethodHead, "/app.css", gzipHeader)
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("ETag") != weak {
+		t.Errorf("gzip HEAD got %q ETag %q, want gzip ETag %q", rec.Header().Get("Content-Encoding"), rec.Header().Get("ETag"), weak)
+	}
+	rec = serveRequest(handler, http.MethodGet, "/app.css", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {weak}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("gzip GET with weak If-None-Match got %d, want 304", rec.Code)
+	}
+}
@@ -0,0 +1,40 @@
+package webimizer
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Cache-Control header value of FaviconHandler responses (one week, favicon url never changes, so it is not immutable)
+const FaviconCacheControl = "public, max-age=604800"
+
+/*
+Create HttpHandler, which serves favicon data from memory (so disk is not accessed on every browser request). Content-Type is detected from data, Cache-Control header is set to FaviconCacheControl and ETag header is computed from data, so conditional GET and HEAD requests get 304 Not Modified status
+Example:
+
+	icon, err := os.ReadFile("static/favicon.ico")
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/favicon.ico", app.FaviconHandler(icon))
+*/
+func FaviconHandler(data []byte) HttpHandler {
+	etag := contentHashETag(data)
+	contentType := http.DetectContentType(data)
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		h := rw.Header()
+		h.Set("Content-Type", contentType)
+		h.Set("Cache-Control", FaviconCacheControl)
+		// icons are small and mostly compressed already, so ETag stays valid for single representation
+		disableGzip(rw)
+		if !conditionalMethod(r.Method) {
+			h.Set("Content-Length", strconv.Itoa(len(data)))
+			rw.Write(data)
+			return
+		}
+		h.Set("ETag", etag)
+		http.ServeContent(rw, r, "", time.Time{}, bytes.NewReader(data))
+	})
+}
@@ -0,0 +1,34 @@
+package webimizer
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestFaviconHandler(t *testing.T) {
+	// ICO file header
+	icon := append([]byte{0, 0, 1, 0, 1, 0}, bytes.Repeat([]byte{0xff}, 2000)...)
+	handler := FaviconHandler(icon)
+	rec := serveRequest(handler, http.MethodGet, "/favicon.ico", http.Header{"Accept-Encoding": {"gzip"}})
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || !bytes.Equal(rec.Body.Bytes(), icon) {
+		t.Fatalf("first fetch got %d with ETag %q and %d bytes, want 200 with ETag and icon", rec.Code, etag, rec.Body.Len())
+	}
+	if rec.Header().Get("Content-Type") != "image/x-icon" || rec.Header().Get("Cache-Control") != FaviconCacheControl {
+		t.Errorf("Content-Type %q, Cache-Control %q", rec.Header().Get("Content-Type"), rec.Header().Get("Cache-Control"))
+	}
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Error("favicon is compressed")
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/favicon.ico", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 || rec.Header().Get("ETag") != etag {
+		t.Errorf("second fetch got %d with ETag %q and %d bytes, want 304 with matching ETag", rec.Code, rec.Header().Get("ETag"), rec.Body.Len())
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/favicon.ico", http.Header{"Accept-Encoding": {"gzip, identity;q=0"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" || gunzip(t, rec.Body.Bytes()) != string(icon) {
+		t.Errorf("identity;q=0 got %d %q, want compressed icon", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+}
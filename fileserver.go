@@ -0,0 +1,518 @@
+package webimizer
+
+import (
+	"bytes"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+Cache-Control header value, which is used for files matched by FileServerStruct.ImmutablePattern
+*/
+const ImmutableCacheControl = "public, max-age=31536000, immutable"
+
+const wellKnownPath = "/.well-known"
+
+/*
+The file server struct, where You can define FileSystem (served filesystem, for example http.Dir("./static")) and optional file server settings.
+You must call func Build to build HttpHandler.
+
+ImmutablePattern (optional): if served filename matches it, Cache-Control header is set to ImmutableCacheControl (use for fingerprinted assets like app.abc123.js). Other files get the default cache policy
+
+HideDotFiles (optional): if true, files and directories with names starting with dot are not served (404 status). Paths under /.well-known/ (ACME challenges, security.txt) are always served
+
+Precompressed and PrecompressedBrotli (optional): if true and client accepts gzip (or br) encoding, precompressed sibling file (for example, app.js.gz or app.js.br) is served instead of requested file with Content-Encoding header. Brotli is preferred over gzip
+
+PathRewrite (optional): func, which can map requested path to another file path before opening (for example, "/old-page" to "/new-page.html"). Returning the same path means no rewrite. Rewritten path is cleaned, so it can not point outside FileSystem root
+
+MinifiedAssets (optional): if true, minified variant (app.min.js, style.min.css) is served instead of requested .js or .css file (if exists). In debug mode (DebugAssets is true or request has non-empty DebugQueryParam query parameter, for example ?debug=1) unminified variant is served instead
+
+ContentLanguage and LanguagePrefixes (optional): Content-Language header value for served files. LanguagePrefixes maps file path prefix to language (for example, "/fr/": "fr"), the longest matching prefix is used. Files, which do not match any prefix, get ContentLanguage (if set)
+
+RootDocument (optional): file name, which is served for / path instead of index.html (for example, "home.html"). Other directories still use index.html
+
+DisableRanges (optional): if true, Range requests get full response with 200 status and Accept-Ranges: none header is sent
+
+InlineExtensions (optional): file extensions (for example, ".pdf"), for which Content-Disposition: inline header is set, so browser displays file instead of downloading it
+
+LanguageDirs (optional): language subdirectory names (for example, "en", "fr"). Requested path (for example, /about) is resolved to file in subdirectory of the best language from Accept-Language request header (/fr/about or /fr/about.html), the first LanguageDirs item is default language. If file is not found in language subdirectories, requested path is served as is. Content-Language header is set to language of subdirectory (if LanguagePrefixes does not match)
+
+CacheBustAssets (optional): if true, relative links in src and href attributes of served .html files (for example, <script src="app.js">) get ?v=<content hash> of linked file, so browser loads new asset version after it changes. Links to other origins, missing and hidden files are not changed. Precompressed siblings of .html files are not served, so rewritten document is compressed on the fly
+
+CompressBrotli (optional): if true and client prefers br encoding, files with Content-Type listed in BrotliContentTypes (up to 1 MB) are compressed with brotli on the fly (if precompressed sibling is not served). Compressed files are cached in memory until file changes. NewBrotliWriter must be set
+
+DirectoryListing (optional): if true, directories without index document are listed as HTML page (instead of 404 status). Listing is written through http.ResponseWriter of handler, so it is compressed by ServeHTTP like other responses
+
+FileHeaders (optional): extra response headers for served files, map key is file extension (for example, ".wasm") or glob pattern of file name (for example, "sw-*.js"). If several keys match, headers are applied in sorted key order
+Example:
+
+	FileHeaders: map[string]http.Header{
+		".wasm": {"Cross-Origin-Embedder-Policy": {"require-corp"}, "Cross-Origin-Opener-Policy": {"same-origin"}},
+	}
+
+NotFoundHTML (optional): HTML document, which is served with 404 status, if FileSystem has no error404.html file (for example, embedded filesystem with assets only). If it is empty, plain text 404 is sent
+
+NotFoundPages (optional): maps file extension of missing file (for example, ".html", or "" for paths without extension) to document, which is served with 404 status instead of error404.html (for example, "/404.html"). Empty document means plain text 404 (for example, for missing images). Other extensions use error404.html
+Example:
+
+	NotFoundPages: map[string]string{".html": "/404.html", "": "/404.html", ".png": "", ".jpg": ""}
+*/
+type FileServerStruct struct {
+	FileSystem          http.FileSystem
+	ImmutablePattern    *regexp.Regexp
+	HideDotFiles        bool
+	Precompressed       bool
+	PrecompressedBrotli bool
+	PathRewrite         func(path string) string
+	MinifiedAssets      bool
+	DebugAssets         bool
+	DebugQueryParam     string
+	ContentLanguage     string
+	LanguagePrefixes    map[string]string
+	RootDocument        string
+	DisableRanges       bool
+	InlineExtensions    []string
+	LanguageDirs        []string
+	CacheBustAssets     bool
+	CompressBrotli      bool
+	DirectoryListing    bool
+	FileHeaders         map[string]http.Header
+	NotFoundHTML        string
+	NotFoundPages       map[string]string
+}
+
+/*
+struct for serving filesystem
+*/
+type neuteredFileSystem struct {
+	fs          http.FileSystem
+	w           http.ResponseWriter
+	r           *http.Request
+	config      FileServerStruct
+	etags       *etagCache
+	assetHashes *assetHashCache
+	brotli      *brotliCache
+}
+
+/*
+Build HttpHandler for serving files from FileSystem.
+If file not found return 404 status and serve error404.html if exist
+*/
+func (builder FileServerStruct) Build() HttpHandler {
+	etags := &etagCache{etags: map[string]string{}}
+	assetHashes := &assetHashCache{hashes: map[string]assetHash{}, documents: map[string]bustedDocument{}}
+	brotli := &brotliCache{entries: map[string]brotliEntry{}}
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		// http.FileServer sets Content-Length, but files are still compressed
+		EnableGzipStreaming(rw)
+		http.FileServer(neuteredFileSystem{fs: builder.FileSystem, w: rw, r: r, config: builder, etags: etags, assetHashes: assetHashes, brotli: brotli}).ServeHTTP(rw, r)
+	})
+	if builder.DisableRanges {
+		handler = handler.WithoutRanges()
+	}
+	return handler
+}
+
+/*
+Create http Handler for serving files in fsPath directory.
+If file not found return 404 status and serve error404.html if exist
+*/
+func NewFileServerHandler(fsPath string) HttpHandler {
+	return FileServerStruct{FileSystem: http.Dir(fsPath)}.Build()
+}
+
+/*
+Create http Handler for serving files from fs.FS (for example, embed.FS).
+If file not found return 404 status and serve error404.html if exist
+*/
+func NewFSFileServerHandler(fsys fs.FS) HttpHandler {
+	return FileServerStruct{FileSystem: http.FS(fsys)}.Build()
+}
+
+/*
+Read and send requested file to client
+If file not found return 404 status and serve 404 document file if error404.html exist
+*/
+func (nfs neuteredFileSystem) Open(path string) (http.File, error) {
+	errorHandler := func() (http.File, error) {
+		document := "/error404.html"
+		if fallback, ok := nfs.config.NotFoundPages[strings.ToLower(filepath.Ext(path))]; ok {
+			if fallback == "" {
+				return nil, fs.ErrNotExist
+			}
+			document = cleanPath(fallback)
+		}
+		f, err := nfs.fs.Open(document)
+		if err == nil {
+			if s, statErr := f.Stat(); statErr != nil || s.IsDir() {
+				// directory can not be served as error document
+				f.Close()
+				f, err = nil, fs.ErrNotExist
+			}
+		}
+		if err != nil {
+			if nfs.config.NotFoundHTML == "" {
+				// plain text 404 is sent by http.FileServer
+				return nil, err
+			}
+			f = &memoryFile{Reader: bytes.NewReader([]byte(nfs.config.NotFoundHTML)), info: memoryFileInfo{name: "error404.html", size: int64(len(nfs.config.NotFoundHTML))}}
+		}
+		contentType := "text/html; charset=utf-8"
+		if err == nil {
+			if t := mime.TypeByExtension(filepath.Ext(document)); t != "" {
+				contentType = t
+			}
+		}
+		nfs.w.Header().Set("Content-Type", contentType)
+		nfs.w.WriteHeader(http.StatusNotFound)
+		return f, nil
+	}
+	resolved, ok := nfs.resolvePath(path)
+	path = resolved
+	if !ok {
+		return errorHandler()
+	}
+	f, err := nfs.fs.Open(path)
+	if err != nil {
+		if nfs.config.DirectoryListing && (strings.HasSuffix(path, "/index.html") || path == nfs.indexPath("/")) {
+			// http.FileServer checks index document of listed directory, so error document must not be served
+			return nil, err
+		}
+		return errorHandler()
+	}
+
+	s, _ := f.Stat()
+	if s.IsDir() {
+		index, err := nfs.fs.Open(nfs.indexPath(path))
+		if err == nil {
+			index.Close()
+		} else if nfs.config.DirectoryListing {
+			return &listingDir{File: f, hideDotFiles: nfs.config.HideDotFiles}, nil
+		} else {
+			closeErr := f.Close()
+			if closeErr != nil {
+				return errorHandler()
+			}
+
+			return errorHandler()
+		}
+	} else {
+		nfs.setFileHeaders(path)
+		servedPath := path
+		if nfs.config.CacheBustAssets && isHTMLFile(path) {
+			// precompressed sibling is not rewritten, so rewritten document is compressed by ServeHTTP instead
+			f = nfs.cacheBustHTML(path, f)
+		} else if sibling, siblingPath := nfs.openPrecompressed(path); sibling != nil {
+			f.Close()
+			f, servedPath = sibling, siblingPath
+		} else if compressed, compressedPath := nfs.openBrotli(path, f); compressed != nil {
+			f.Close()
+			// content hash ETag of compressed file must differ from uncompressed one
+			f, servedPath = compressed, compressedPath
+		}
+		if servedPath != "" {
+			nfs.setContentETag(servedPath, f)
+		}
+	}
+
+	return f, nil
+}
+
+/*
+Get path of file in FileSystem for requested path (RootDocument, PathRewrite, LanguageDirs and MinifiedAssets are applied). false is returned, if path is hidden by HideDotFiles (rewritten path is returned anyway)
+*/
+func (nfs neuteredFileSystem) resolvePath(path string) (string, bool) {
+	if path == "/index.html" && nfs.config.RootDocument != "" {
+		// http.FileServer opens index.html of requested directory
+		path = nfs.indexPath("/")
+	}
+	if nfs.config.PathRewrite != nil {
+		path = cleanPath(nfs.config.PathRewrite(path))
+	}
+	if nfs.config.HideDotFiles && isHiddenPath(path) {
+		return path, false
+	}
+	if len(nfs.config.LanguageDirs) > 0 {
+		path = nfs.languagePath(path)
+	}
+	if nfs.config.MinifiedAssets {
+		path = nfs.assetVariant(path)
+	}
+	return path, true
+}
+
+/*
+Directory, which is listed by http.FileServer (files with names starting with dot are not listed, if hideDotFiles is true)
+*/
+type listingDir struct {
+	http.File
+	hideDotFiles bool
+}
+
+func (d *listingDir) Readdir(count int) ([]fs.FileInfo, error) {
+	entries, err := d.File.Readdir(count)
+	if !d.hideDotFiles {
+		return entries, err
+	}
+	visible := entries[:0]
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") {
+			visible = append(visible, entry)
+		}
+	}
+	return visible, err
+}
+
+/*
+Find precompressed sibling file (.br or .gz), which is accepted by client. If found, Content-Encoding and Content-Type headers are set
+*/
+func (nfs neuteredFileSystem) openPrecompressed(path string) (http.File, string) {
+	if !nfs.config.Precompressed && !nfs.config.PrecompressedBrotli {
+		return nil, ""
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+	if contentType == "" {
+		// served file name is sibling name, so Content-Type can not be detected by extension
+		return nil, ""
+	}
+	acceptEncoding := nfs.r.Header.Get("Accept-Encoding")
+	addVary(nfs.w.Header(), "Accept-Encoding")
+	siblings := []struct {
+		enabled  bool
+		encoding string
+		ext      string
+	}{
+		{nfs.config.PrecompressedBrotli, "br", ".br"},
+		{nfs.config.Precompressed, "gzip", ".gz"},
+	}
+	for _, sibling := range siblings {
+		if !sibling.enabled || acceptEncodingQuality(acceptEncoding, sibling.encoding) <= 0 {
+			continue
+		}
+		f, err := nfs.fs.Open(path + sibling.ext)
+		if err != nil {
+			continue
+		}
+		if s, err := f.Stat(); err != nil || s.IsDir() {
+			f.Close()
+			continue
+		}
+		nfs.w.Header().Set("Content-Encoding", sibling.encoding)
+		nfs.w.Header().Set("Content-Type", contentType)
+		disableGzip(nfs.w)
+		return f, path + sibling.ext
+	}
+	return nil, ""
+}
+
+/*
+Get path of file in the best language subdirectory (from LanguageDirs), which exists. If path already is in language subdirectory or file is not found, path is returned unchanged
+*/
+func (nfs neuteredFileSystem) languagePath(name string) string {
+	addVary(nfs.w.Header(), "Accept-Language")
+	for _, dir := range nfs.config.LanguageDirs {
+		if name == "/"+dir || strings.HasPrefix(name, "/"+dir+"/") {
+			return name
+		}
+	}
+	for _, lang := range negotiateLanguage(nfs.r.Header.Get("Accept-Language"), nfs.config.LanguageDirs) {
+		candidates := []string{"/" + lang + name}
+		if path.Ext(name) == "" && !strings.HasSuffix(name, "/") {
+			candidates = append(candidates, "/"+lang+name+".html")
+		}
+		for _, candidate := range candidates {
+			if f, err := nfs.fs.Open(candidate); err == nil {
+				f.Close()
+				return candidate
+			}
+		}
+	}
+	return name
+}
+
+/*
+Get languages (from available) ordered by preference of Accept-Language request header. Language range matches language with the same primary subtag (for example, "fr-CA" matches "fr"), the first available language (default) is always included
+*/
+func negotiateLanguage(header string, available []string) []string {
+	type match struct {
+		lang string
+		q    float64
+	}
+	var matches []match
+	for _, item := range strings.Split(header, ",") {
+		params := strings.Split(item, ";")
+		tag := strings.ToLower(strings.TrimSpace(params[0]))
+		if tag == "" {
+			continue
+		}
+		q, ok := parseQValue(params[1:])
+		if !ok || q <= 0 {
+			continue
+		}
+		for _, lang := range available {
+			l := strings.ToLower(lang)
+			if tag == "*" || tag == l || strings.HasPrefix(tag, l+"-") || strings.HasPrefix(l, tag+"-") {
+				matches = append(matches, match{lang, q})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].q > matches[j].q })
+	var langs []string
+	for _, m := range matches {
+		if !containsString(langs, m.lang) {
+			langs = append(langs, m.lang)
+		}
+	}
+	if len(available) > 0 && !containsString(langs, available[0]) {
+		langs = append(langs, available[0])
+	}
+	return langs
+}
+
+func isHTMLFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".html" || ext == ".htm"
+}
+
+/*
+Get minified (or unminified in debug mode) variant of .js or .css file path, if variant file exists
+*/
+func (nfs neuteredFileSystem) assetVariant(name string) string {
+	ext := filepath.Ext(name)
+	if ext != ".js" && ext != ".css" {
+		return name
+	}
+	base := strings.TrimSuffix(name, ext)
+	minified := strings.HasSuffix(base, ".min")
+	var variant string
+	if nfs.debugAssets() {
+		if !minified {
+			return name
+		}
+		variant = strings.TrimSuffix(base, ".min") + ext
+	} else {
+		if minified {
+			return name
+		}
+		variant = base + ".min" + ext
+	}
+	f, err := nfs.fs.Open(variant)
+	if err != nil {
+		return name
+	}
+	f.Close()
+	return variant
+}
+
+func (nfs neuteredFileSystem) debugAssets() bool {
+	if nfs.config.DebugAssets {
+		return true
+	}
+	return nfs.config.DebugQueryParam != "" && nfs.r.URL.Query().Get(nfs.config.DebugQueryParam) != ""
+}
+
+/*
+Clean path and make it absolute, so it can not contain ".." elements
+*/
+func cleanPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+/*
+Check if path contains file or directory name starting with dot (except /.well-known/ directory)
+*/
+func isHiddenPath(path string) bool {
+	if path == wellKnownPath || strings.HasPrefix(path, wellKnownPath+"/") {
+		path = path[len(wellKnownPath):]
+	}
+	for _, name := range strings.Split(path, "/") {
+		if strings.HasPrefix(name, ".") && name != "." && name != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Get index document path of directory
+*/
+func (nfs neuteredFileSystem) indexPath(dir string) string {
+	if dir == "/" && nfs.config.RootDocument != "" {
+		return cleanPath(nfs.config.RootDocument)
+	}
+	return filepath.Join(dir, "index.html")
+}
+
+/*
+Set Http response headers for served file
+*/
+func (nfs neuteredFileSystem) setFileHeaders(path string) {
+	if nfs.config.ImmutablePattern != nil && nfs.config.ImmutablePattern.MatchString(filepath.Base(path)) {
+		nfs.w.Header().Set("Cache-Control", ImmutableCacheControl)
+	}
+	if lang := nfs.config.contentLanguage(path); lang != "" {
+		nfs.w.Header().Set("Content-Language", lang)
+	}
+	ext := filepath.Ext(path)
+	for _, inline := range nfs.config.InlineExtensions {
+		if strings.EqualFold(ext, inline) {
+			nfs.w.Header().Set("Content-Disposition", "inline")
+			break
+		}
+	}
+	if len(nfs.config.FileHeaders) > 0 {
+		nfs.setExtraFileHeaders(path)
+	}
+}
+
+/*
+Set headers of FileHeaders keys, which match file path
+*/
+func (nfs neuteredFileSystem) setExtraFileHeaders(name string) {
+	keys := make([]string, 0, len(nfs.config.FileHeaders))
+	for key := range nfs.config.FileHeaders {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	base := filepath.Base(name)
+	for _, key := range keys {
+		matched := false
+		if strings.HasPrefix(key, ".") && !strings.ContainsAny(key, "*?[") {
+			matched = strings.EqualFold(filepath.Ext(base), key)
+		} else {
+			matched, _ = filepath.Match(key, base)
+		}
+		if !matched {
+			continue
+		}
+		for k, v := range nfs.config.FileHeaders[key] {
+			nfs.w.Header()[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+}
+
+/*
+Get Content-Language for file path from LanguagePrefixes (the longest matching prefix) or ContentLanguage
+*/
+func (config FileServerStruct) contentLanguage(path string) string {
+	lang, matched := config.ContentLanguage, 0
+	for prefix, prefixLang := range config.LanguagePrefixes {
+		if strings.HasPrefix(path, prefix) && len(prefix) > matched {
+			lang, matched = prefixLang, len(prefix)
+		}
+	}
+	if matched == 0 {
+		for _, dir := range config.LanguageDirs {
+			if strings.HasPrefix(path, "/"+dir+"/") {
+				return dir
+			}
+		}
+	}
+	return lang
+}
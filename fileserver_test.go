@@ -0,0 +1,609 @@
+package webimizer
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+)
+
+//go:embed testdata/site
+var testSite embed.FS
+
+func testSiteFS(t *testing.T) fs.FS {
+	t.Helper()
+	fsys, err := fs.Sub(testSite, "testdata/site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fsys
+}
+
+/*
+Serve request by handler (through ServeHTTP, so default headers and gzip are applied)
+*/
+func serveRequest(handler http.Handler, method, target string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+/*
+Maximum number of bytes, which http.ServeContent reads for Content-Type detection
+*/
+const sniffLen = 512
+
+/*
+http.FileSystem, which counts bytes read from opened files
+*/
+type countingFileSystem struct {
+	http.FileSystem
+	read *atomic.Int64
+}
+
+type countingFile struct {
+	http.File
+	read *atomic.Int64
+}
+
+func (cfs countingFileSystem) Open(name string) (http.File, error) {
+	f, err := cfs.FileSystem.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if mf, ok := f.(*memoryFile); ok {
+		// keep precomputed ETag of memory file
+		return &countingMemoryFile{memoryFile: mf, read: cfs.read}, nil
+	}
+	return &countingFile{File: f, read: cfs.read}, nil
+}
+
+func (f *countingFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	f.read.Add(int64(n))
+	return n, err
+}
+
+type countingMemoryFile struct {
+	*memoryFile
+	read *atomic.Int64
+}
+
+func (f *countingMemoryFile) Read(b []byte) (int, error) {
+	n, err := f.memoryFile.Read(b)
+	f.read.Add(int64(n))
+	return n, err
+}
+
+func TestEmbeddedFSConditionalGet(t *testing.T) {
+	handler := NewFSFileServerHandler(testSiteFS(t))
+	rec := serveRequest(handler, http.MethodGet, "/hello.txt", nil)
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" {
+		t.Fatalf("got %d with ETag %q, want 200 with ETag", rec.Code, etag)
+	}
+	if rec.Body.String() != "hello from embedded filesystem\n" {
+		t.Fatalf("unexpected body %q", rec.Body.String())
+	}
+	rec = serveRequest(handler, http.MethodGet, "/hello.txt", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET got %d, want 304", rec.Code)
+	}
+	rec = serveRequest(handler, http.MethodGet, "/hello.txt", http.Header{"If-None-Match": {`"other"`}})
+	if rec.Code != http.StatusOK {
+		t.Errorf("GET with different ETag got %d, want 200", rec.Code)
+	}
+}
+
+func TestMemoryFSConditionalGet(t *testing.T) {
+	handler := NewMemoryFileServerHandler(map[string][]byte{"/app.js": []byte("console.log('app')")})
+	rec := serveRequest(handler, http.MethodGet, "/app.js", nil)
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("ETag is not set")
+	}
+	rec = serveRequest(handler, http.MethodGet, "/app.js", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET got %d, want 304", rec.Code)
+	}
+}
+
+func TestHeadDoesNotHashMemoryFile(t *testing.T) {
+	var read atomic.Int64
+	content := bytes.Repeat([]byte("a"), 1<<20)
+	fsys := countingFileSystem{FileSystem: newMemoryFileSystem(map[string][]byte{"/big.txt": content}), read: &read}
+	handler := FileServerStruct{FileSystem: fsys}.Build()
+	rec := serveRequest(handler, http.MethodHead, "/big.txt", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("HEAD got %d, want 200", rec.Code)
+	}
+	if n := read.Load(); n > sniffLen {
+		t.Errorf("HEAD read %d bytes, want at most %d", n, sniffLen)
+	}
+	if rec.Header().Get("ETag") != contentHashETag(content) {
+		t.Errorf("HEAD ETag = %q, want precomputed content hash", rec.Header().Get("ETag"))
+	}
+}
+
+func TestHeadDoesNotHashUnknownModTimeFile(t *testing.T) {
+	var read atomic.Int64
+	content := bytes.Repeat([]byte("a"), 1<<20)
+	// files of fstest.MapFS have no modification time
+	fsys := countingFileSystem{FileSystem: http.FS(fstest.MapFS{"big.txt": {Data: content}}), read: &read}
+	handler := FileServerStruct{FileSystem: fsys}.Build()
+	for _, header := range []http.Header{nil, {"Accept-Encoding": {"gzip"}}} {
+		read.Store(0)
+		rec := serveRequest(handler, http.MethodHead, "/big.txt", header)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("HEAD got %d, want 200", rec.Code)
+		}
+		if n := read.Load(); n > sniffLen {
+			t.Errorf("HEAD (Accept-Encoding %q) read %d bytes, want at most %d", header.Get("Accept-Encoding"), n, sniffLen)
+		}
+	}
+	// ETag is computed by GET and reused by HEAD
+	etag := serveRequest(handler, http.MethodGet, "/big.txt", nil).Header().Get("ETag")
+	read.Store(0)
+	if got := serveRequest(handler, http.MethodHead, "/big.txt", nil).Header().Get("ETag"); got != etag || etag != contentHashETag(content) {
+		t.Errorf("HEAD ETag = %q, GET ETag %q, want %q", got, etag, contentHashETag(content))
+	}
+	if n := read.Load(); n > sniffLen {
+		t.Errorf("HEAD after GET read %d bytes, want at most %d", n, sniffLen)
+	}
+}
+
+func TestImmutablePattern(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem:       newMemoryFileSystem(map[string][]byte{"/app.abc123.js": []byte("hashed"), "/app.js": []byte("plain")}),
+		ImmutablePattern: regexp.MustCompile(`\.[0-9a-f]{6,}\.(js|css)$`),
+	}.Build()
+
+	rec := serveRequest(handler, http.MethodGet, "/app.abc123.js", nil)
+	if got := rec.Header().Get("Cache-Control"); got != ImmutableCacheControl {
+		t.Errorf("hashed file Cache-Control = %q, want %q", got, ImmutableCacheControl)
+	}
+	rec = serveRequest(handler, http.MethodGet, "/app.js", nil)
+	if got := rec.Header().Get("Cache-Control"); got == ImmutableCacheControl {
+		t.Errorf("plain file Cache-Control = %q, want default cache policy", got)
+	}
+}
+
+func TestHideDotFilesWellKnown(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/.well-known/acme-challenge/token": []byte("challenge"),
+			"/.well-known/.secret":              []byte("secret"),
+			"/.env":                             []byte("SECRET=1"),
+			"/.git/config":                      []byte("[core]"),
+		}),
+		HideDotFiles: true,
+	}.Build()
+
+	rec := serveRequest(handler, http.MethodGet, "/.well-known/acme-challenge/token", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "challenge" {
+		t.Errorf("/.well-known/acme-challenge/token got %d %q, want 200 challenge", rec.Code, rec.Body.String())
+	}
+	for _, path := range []string{"/.env", "/.git/config", "/.well-known/.secret"} {
+		if rec := serveRequest(handler, http.MethodGet, path, nil); rec.Code != http.StatusNotFound {
+			t.Errorf("%s got %d, want 404", path, rec.Code)
+		}
+	}
+}
+
+func TestPrecompressedSiblings(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/app.js":    []byte("plain"),
+			"/app.js.gz": []byte("gzip data"),
+			"/app.js.br": []byte("brotli data"),
+		}),
+		Precompressed:       true,
+		PrecompressedBrotli: true,
+	}.Build()
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"gzip, br", "br", "brotli data"},
+		{"gzip", "gzip", "gzip data"},
+		{"br;q=0, gzip", "gzip", "gzip data"},
+		{"", "", "plain"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.acceptEncoding, func(t *testing.T) {
+			rec := serveRequest(handler, http.MethodGet, "/app.js", http.Header{"Accept-Encoding": {tt.acceptEncoding}})
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != tt.encoding || rec.Body.String() != tt.body {
+				t.Errorf("got %d %q %q, want %q %q", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String(), tt.encoding, tt.body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+				t.Errorf("Content-Type = %q, want type of requested file", ct)
+			}
+		})
+	}
+}
+
+/*
+Create file in directory (parent directories are created too)
+*/
+func writeTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	name = filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPathRewrite(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeTestFile(t, root, "new-page.html", "new page")
+	writeTestFile(t, dir, "secret.txt", "secret")
+	handler := FileServerStruct{
+		FileSystem: http.Dir(root),
+		PathRewrite: func(path string) string {
+			switch path {
+			case "/old-page":
+				return "/new-page.html"
+			case "/escape":
+				return "../secret.txt"
+			}
+			return path
+		},
+	}.Build()
+
+	rec := serveRequest(handler, http.MethodGet, "/old-page", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "new page" {
+		t.Errorf("/old-page got %d %q, want 200 new page", rec.Code, rec.Body.String())
+	}
+	rec = serveRequest(handler, http.MethodGet, "/new-page.html", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "new page" {
+		t.Errorf("/new-page.html got %d %q, want 200 new page", rec.Code, rec.Body.String())
+	}
+	rec = serveRequest(handler, http.MethodGet, "/escape", nil)
+	if rec.Code != http.StatusNotFound || strings.Contains(rec.Body.String(), "secret") {
+		t.Errorf("/escape got %d %q, want 404 (rewritten path must stay in root)", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMinifiedAssets(t *testing.T) {
+	files := map[string][]byte{
+		"/app.js":      []byte("unminified js"),
+		"/app.min.js":  []byte("minified js"),
+		"/style.css":   []byte("unminified css"),
+		"/only.js":     []byte("only variant"),
+		"/lib.min.css": []byte("minified only"),
+	}
+	tests := []struct {
+		name   string
+		config FileServerStruct
+		target string
+		want   string
+	}{
+		{"production", FileServerStruct{MinifiedAssets: true}, "/app.js", "minified js"},
+		{"production minified requested", FileServerStruct{MinifiedAssets: true}, "/app.min.js", "minified js"},
+		{"production without minified variant", FileServerStruct{MinifiedAssets: true}, "/only.js", "only variant"},
+		{"debug option", FileServerStruct{MinifiedAssets: true, DebugAssets: true}, "/app.js", "unminified js"},
+		{"debug option minified requested", FileServerStruct{MinifiedAssets: true, DebugAssets: true}, "/app.min.js", "unminified js"},
+		{"debug without unminified variant", FileServerStruct{MinifiedAssets: true, DebugAssets: true}, "/lib.min.css", "minified only"},
+		{"debug query param", FileServerStruct{MinifiedAssets: true, DebugQueryParam: "debug"}, "/app.js?debug=1", "unminified js"},
+		{"empty debug query param", FileServerStruct{MinifiedAssets: true, DebugQueryParam: "debug"}, "/app.js?debug=", "minified js"},
+		{"disabled", FileServerStruct{}, "/app.js", "unminified js"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.FileSystem = newMemoryFileSystem(files)
+			rec := serveRequest(config.Build(), http.MethodGet, tt.target, nil)
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+				t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHeadDoesNotReadLargeFile(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("large file content\n", 64<<10)
+	writeTestFile(t, dir, "large.txt", content)
+	var read atomic.Int64
+	handler := FileServerStruct{FileSystem: countingFileSystem{FileSystem: http.Dir(dir), read: &read}}.Build()
+
+	rec := serveRequest(handler, http.MethodHead, "/large.txt", nil)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("HEAD got %d with %d body bytes, want 200 without body", rec.Code, rec.Body.Len())
+	}
+	if n := read.Load(); n > sniffLen {
+		t.Errorf("HEAD read %d bytes of %d byte file, want at most %d", n, len(content), sniffLen)
+	}
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(content)) {
+		t.Errorf("HEAD Content-Length = %q, want %d", got, len(content))
+	}
+
+	read.Store(0)
+	rec = serveRequest(handler, http.MethodHead, "/large.txt", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip HEAD got %d %q, want 200 gzip", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if n := read.Load(); n > sniffLen {
+		t.Errorf("gzip HEAD read %d bytes of %d byte file, want at most %d", n, len(content), sniffLen)
+	}
+}
+
+func TestContentLanguage(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/about.html":        []byte("about"),
+			"/fr/about.html":     []byte("à propos"),
+			"/fr/ca/about.html":  []byte("à propos (Canada)"),
+			"/french/notes.html": []byte("notes"),
+		}),
+		ContentLanguage:  "en",
+		LanguagePrefixes: map[string]string{"/fr/": "fr", "/fr/ca/": "fr-CA"},
+	}.Build()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/about.html", "en"},
+		{"/fr/about.html", "fr"},
+		{"/fr/ca/about.html", "fr-CA"},
+		{"/french/notes.html", "en"},
+	}
+	for _, tt := range tests {
+		if got := serveRequest(handler, http.MethodGet, tt.path, nil).Header().Get("Content-Language"); got != tt.want {
+			t.Errorf("%s Content-Language = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRootDocument(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/home.html":      []byte("home"),
+			"/index.html":     []byte("root index"),
+			"/sub/index.html": []byte("sub index"),
+			"/sub/home.html":  []byte("sub home"),
+		}),
+		RootDocument: "home.html",
+	}.Build()
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/", "home"},
+		{"/sub/", "sub index"},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(handler, http.MethodGet, tt.target, nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+			t.Errorf("%s got %d %q, want 200 %q", tt.target, rec.Code, rec.Body.String(), tt.want)
+		}
+	}
+}
+
+func TestDisableRanges(t *testing.T) {
+	content := []byte("0123456789abcdef")
+	for _, disabled := range []bool{false, true} {
+		handler := FileServerStruct{FileSystem: newMemoryFileSystem(map[string][]byte{"/data.bin": content}), DisableRanges: disabled}.Build()
+		rec := serveRequest(handler, http.MethodGet, "/data.bin", http.Header{"Range": {"bytes=0-3"}})
+		if !disabled {
+			if rec.Code != http.StatusPartialContent || rec.Body.String() != "0123" {
+				t.Errorf("ranges enabled got %d %q, want 206 0123", rec.Code, rec.Body.String())
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK || !bytes.Equal(rec.Body.Bytes(), content) {
+			t.Errorf("ranges disabled got %d %q, want 200 full content", rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get("Accept-Ranges"); got != "none" {
+			t.Errorf("Accept-Ranges = %q, want none", got)
+		}
+		if rec.Header().Get("Content-Range") != "" {
+			t.Error("Content-Range header is sent")
+		}
+	}
+}
+
+func TestInlineExtensions(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem:       newMemoryFileSystem(map[string][]byte{"/doc.pdf": []byte("%PDF-1.4"), "/DOC2.PDF": []byte("%PDF-1.4"), "/files.zip": []byte("PK")}),
+		InlineExtensions: []string{".pdf"},
+	}.Build()
+	for _, path := range []string{"/doc.pdf", "/DOC2.PDF"} {
+		if got := serveRequest(handler, http.MethodGet, path, nil).Header().Get("Content-Disposition"); got != "inline" {
+			t.Errorf("%s Content-Disposition = %q, want inline", path, got)
+		}
+	}
+	if got := serveRequest(handler, http.MethodGet, "/files.zip", nil).Header().Values("Content-Disposition"); len(got) != 0 {
+		t.Errorf("/files.zip Content-Disposition = %q, want absent", got)
+	}
+}
+
+func TestPrecompressedGzipSingleEncoding(t *testing.T) {
+	compressed := gzipBytes(t, []byte(strings.Repeat("console.log('webimizer');\n", 100)))
+	handler := FileServerStruct{
+		FileSystem:    newMemoryFileSystem(map[string][]byte{"/app.js": []byte("plain"), "/app.js.gz": compressed}),
+		Precompressed: true,
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/app.js", http.Header{"Accept-Encoding": {"gzip"}})
+	if got := rec.Header().Values("Content-Encoding"); len(got) != 1 || got[0] != "gzip" {
+		t.Errorf("Content-Encoding = %q, want single gzip", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), compressed) {
+		t.Error("precompressed file is encoded again")
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want single Accept-Encoding", vary)
+	}
+}
+
+func TestLanguageDirs(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/en/about.html": []byte("about"),
+			"/fr/about.html": []byte("à propos"),
+			"/de/about.html": []byte("über"),
+			"/logo.png":      []byte("png"),
+		}),
+		LanguageDirs: []string{"en", "fr", "de"},
+	}.Build()
+	tests := []struct {
+		acceptLanguage string
+		path           string
+		body           string
+		lang           string
+	}{
+		{"fr", "/about", "à propos", "fr"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", "/about", "à propos", "fr"},
+		{"de;q=0.5, fr;q=0.9", "/about.html", "à propos", "fr"},
+		{"es", "/about", "about", "en"},
+		{"", "/about", "about", "en"},
+		{"fr", "/logo.png", "png", ""},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(handler, http.MethodGet, tt.path, http.Header{"Accept-Language": {tt.acceptLanguage}})
+		if rec.Code != http.StatusOK || rec.Body.String() != tt.body || rec.Header().Get("Content-Language") != tt.lang {
+			t.Errorf("Accept-Language %q %s got %d %q Content-Language %q, want %q %q", tt.acceptLanguage, tt.path, rec.Code, rec.Body.String(), rec.Header().Get("Content-Language"), tt.body, tt.lang)
+		}
+	}
+}
+
+func TestErrorDocumentDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "index.html", "home")
+	writeTestFile(t, dir, "error404.html/readme.txt", "not an error page")
+	handler := FileServerStruct{FileSystem: http.Dir(dir)}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/missing.html", nil)
+	if rec.Code != http.StatusNotFound || rec.Body.String() != "404 page not found\n" {
+		t.Errorf("got %d %q, want plain text 404", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/plain; charset=utf-8", ct)
+	}
+}
+
+func TestDirectoryListingGzip(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/files/report.pdf": []byte("pdf"),
+			"/files/notes.txt":  []byte("notes"),
+			"/files/.hidden":    []byte("hidden"),
+			"/files/img/a.png":  []byte("png"),
+			"/index.html":       []byte("home"),
+		}),
+		DirectoryListing: true,
+		HideDotFiles:     true,
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/files/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("status %d, Content-Encoding %q, want compressed listing", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/html") {
+		t.Errorf("Content-Type %q, want text/html", got)
+	}
+	listing := gunzip(t, rec.Body.Bytes())
+	for _, name := range []string{`href="report.pdf"`, `href="notes.txt"`, `href="img/"`} {
+		if !strings.Contains(listing, name) {
+			t.Errorf("listing has no %s:\n%s", name, listing)
+		}
+	}
+	if strings.Contains(listing, ".hidden") {
+		t.Error("dot file is listed")
+	}
+}
+
+func TestFileHeaders(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/app.wasm": []byte("\x00asm"),
+			"/app.js":   []byte("wasm loader"),
+			"/sw-v1.js": []byte("service worker"),
+		}),
+		FileHeaders: map[string]http.Header{
+			".WASM": {
+				"Cross-Origin-Opener-Policy":   {"same-origin"},
+				"cross-origin-embedder-policy": {"require-corp"},
+			},
+			"sw-*.js": {"Service-Worker-Allowed": {"/"}},
+		},
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/app.wasm", nil)
+	if rec.Header().Get("Cross-Origin-Opener-Policy") != "same-origin" || rec.Header().Get("Cross-Origin-Embedder-Policy") != "require-corp" {
+		t.Errorf(".wasm COOP %q, COEP %q", rec.Header().Get("Cross-Origin-Opener-Policy"), rec.Header().Get("Cross-Origin-Embedder-Policy"))
+	}
+	rec = serveRequest(handler, http.MethodGet, "/app.js", nil)
+	for _, name := range []string{"Cross-Origin-Opener-Policy", "Cross-Origin-Embedder-Policy", "Service-Worker-Allowed"} {
+		if got := rec.Header().Get(name); got != "" {
+			t.Errorf(".js %s = %q, want absent", name, got)
+		}
+	}
+	if got := serveRequest(handler, http.MethodGet, "/sw-v1.js", nil).Header().Get("Service-Worker-Allowed"); got != "/" {
+		t.Errorf("sw-v1.js Service-Worker-Allowed = %q, want /", got)
+	}
+}
+
+func TestNotFoundHTML(t *testing.T) {
+	page := "<h1>Page not found</h1>"
+	handler := FileServerStruct{FileSystem: http.FS(testSiteFS(t)), NotFoundHTML: page}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/missing.html", nil)
+	if rec.Code != http.StatusNotFound || rec.Body.String() != page {
+		t.Errorf("got %d %q, want 404 with built-in page", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/html", got)
+	}
+	if rec := serveRequest(handler, http.MethodGet, "/hello.txt", nil); rec.Code != http.StatusOK {
+		t.Errorf("existing asset got %d, want 200", rec.Code)
+	}
+
+	rec = serveRequest(NewFSFileServerHandler(testSiteFS(t)), http.MethodGet, "/missing.html", nil)
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "404 page not found") {
+		t.Errorf("without NotFoundHTML got %d %q, want plain text 404", rec.Code, rec.Body.String())
+	}
+}
+
+func TestNotFoundPages(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem: newMemoryFileSystem(map[string][]byte{
+			"/index.html":    []byte("home"),
+			"/404.html":      []byte("custom page"),
+			"/error404.html": []byte("default error page"),
+		}),
+		NotFoundPages: map[string]string{".html": "/404.html", "": "/404.html", ".png": ""},
+	}.Build()
+	tests := []struct {
+		target string
+		body   string
+	}{
+		{"/missing.html", "custom page"},
+		{"/docs/MISSING.HTML", "custom page"},
+		{"/about", "custom page"},
+		{"/logo.png", "404 page not found\n"},
+		{"/app.js", "default error page"},
+	}
+	for _, test := range tests {
+		rec := serveRequest(handler, http.MethodGet, test.target, nil)
+		if rec.Code != http.StatusNotFound || rec.Body.String() != test.body {
+			t.Errorf("%s got %d %q, want 404 %q", test.target, rec.Code, rec.Body.String(), test.body)
+		}
+	}
+}
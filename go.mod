@@ -1,3 +1,3 @@
 module webimizer.dev/webimizer
 
-go 1.17
+go 1.21
@@ -0,0 +1,552 @@
+package webimizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+/*
+Callback for observing gzip compression ratio (optional). It is called when gzipResponseWriter is closed with uncompressed (written by handler) and compressed (sent to client) byte counts
+Example:
+
+	app.OnGzipStats = func(uncompressed, compressed int64) {
+		log.Printf("gzip: %d -> %d bytes", uncompressed, compressed)
+	}
+*/
+var OnGzipStats func(uncompressed, compressed int64)
+
+/*
+Define gzip compression level (from gzip.HuffmanOnly to gzip.BestCompression). If level is invalid, Http response is sent uncompressed
+*/
+var GzipCompressionLevel = gzip.DefaultCompression
+
+/*
+Define minimum response body size in bytes for gzip compression (0 means compress all responses). Smaller responses are sent uncompressed. If handler sets Content-Length header before writing, it is used to decide immediately (response with Content-Length of at least GzipMinLength bytes is compressed and Content-Length is removed), otherwise response is buffered until GzipMinLength bytes are written
+*/
+var GzipMinLength = 0
+
+/*
+Define if compressed Http responses contain X-Content-SHA256 trailer with hex encoded SHA-256 hash of uncompressed body (clients can use it to validate integrity)
+*/
+var GzipTrailerChecksum = false
+
+const checksumTrailer = "X-Content-SHA256"
+
+/*
+Define if gzipResponseWriter detects Content-Type (by http.DetectContentType) when handler does not set it. If false, GzipDefaultContentType is used (if it is empty, Content-Type header is not sent)
+*/
+var GzipSniffContentType = true
+
+/*
+Define Content-Type (for example, "application/octet-stream"), which is used when GzipSniffContentType is false and handler does not set Content-Type
+*/
+var GzipDefaultContentType = ""
+
+/*
+Define Content-Type prefixes of Http responses, which are not compressed (if client does not forbid uncompressed response by "identity;q=0")
+Example:
+
+	app.GzipExcludedContentTypes = []string{"image/", "video/", "audio/", "application/zip"}
+*/
+var GzipExcludedContentTypes []string
+
+/*
+Define status codes of Http responses, which are compressed (if client does not forbid uncompressed response by "identity;q=0"). Responses with other status codes (for example, small 4xx and 5xx error pages) are sent uncompressed. If it is empty, responses with any status code are compressed. Responses with Content-Range header (byte offsets of uncompressed body) are never compressed
+Example:
+
+	app.GzipStatusCodes = append(app.GzipStatusCodes, http.StatusNotFound) // compress custom 404 pages too
+*/
+var GzipStatusCodes = []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNonAuthoritativeInfo, http.StatusNoContent}
+
+/*
+Compressing writer of response body (for example, *gzip.Writer)
+*/
+type responseEncoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+/*
+http.ResponseWriter, which compresses response body (with gzip encoding by default). Compression is decided on first Write or WriteHeader call, so Content-Encoding and Vary headers are set only if response is actually compressed. If varyOnly is true (client does not accept gzip), response is never compressed, but Vary header is set, if it would be compressed
+*/
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	enc          responseEncoder
+	encoding     string
+	uncompressed int64
+	compressed   int64
+	passthrough  bool
+	decided      bool
+	status       int
+	buf          *bytes.Buffer
+	hash         hash.Hash
+	streaming    bool
+	force        bool
+	rejected     bool
+	closed       bool
+	closeErr     error
+	varyOnly     bool
+}
+
+/*
+io.Writer, which counts bytes written to underlying writer
+*/
+type countingWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (cw countingWriter) Write(b []byte) (int, error) {
+	n, err := cw.w.Write(b)
+	*cw.n += int64(n)
+	return n, err
+}
+
+func newGzipResponseWriter(w http.ResponseWriter) (*gzipResponseWriter, error) {
+	gzr := &gzipResponseWriter{ResponseWriter: w}
+	gz, err := gzip.NewWriterLevel(countingWriter{w: w, n: &gzr.compressed}, GzipCompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	gzr.enc = gz
+	gzr.encoding = "gzip"
+	return gzr, nil
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.rejected {
+		return
+	}
+	if w.decided || (status >= 100 && status < 200) {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if !bodyAllowedForStatus(status) {
+		w.start(false)
+	} else if !w.force && (GzipMinLength <= 0 || w.contentLength() >= 0) {
+		// if client forbids uncompressed response, decision waits for body, so empty response is not rejected
+		w.start(true)
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided && w.force && len(b) == 0 {
+		// decision waits for body, so empty response is not rejected
+		return 0, nil
+	}
+	if !w.decided {
+		if _, haveType := w.Header()["Content-Type"]; !haveType {
+			switch {
+			case GzipSniffContentType:
+				// If no content type, apply sniffing algorithm to un-gzipped body. Test
+				w.Header().Set("Content-Type", http.DetectContentType(b))
+			case GzipDefaultContentType != "":
+				w.Header().Set("Content-Type", GzipDefaultContentType)
+			default:
+				// nil value prevents net/http from sniffing compressed body
+				w.Header()["Content-Type"] = nil
+			}
+		}
+		if w.contentLength() < 0 && w.bufferedLen()+len(b) < GzipMinLength {
+			if w.buf == nil {
+				w.buf = getBuffer()
+			}
+			return w.buf.Write(b)
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return w.write(b)
+}
+
+/*
+Copy src to response (io.Copy uses it) with pooled buffer. First chunk is passed to Write, so compression decision is made as usual. If response is not compressed, src is copied by underlying http.ResponseWriter (so sendfile can be used)
+*/
+func (w *gzipResponseWriter) ReadFrom(src io.Reader) (int64, error) {
+	bufp := copyBufferPool.Get().(*[]byte)
+	defer copyBufferPool.Put(bufp)
+	buf := *bufp
+	var written int64
+	for {
+		if w.rejected {
+			n, err := io.CopyBuffer(io.Discard, src, buf)
+			return written + n, err
+		}
+		if w.decided && w.passthrough {
+			if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+				n, err := rf.ReadFrom(src)
+				return written + n, err
+			}
+			n, err := io.CopyBuffer(struct{ io.Writer }{w.ResponseWriter}, src, buf)
+			return written + n, err
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			m, werr := w.Write(buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
+
+func (w *gzipResponseWriter) write(b []byte) (int, error) {
+	if w.rejected {
+		// 406 Not Acceptable is sent instead of uncompressed body
+		return len(b), nil
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	n, err := w.enc.Write(b)
+	w.uncompressed += int64(n)
+	if w.hash != nil {
+		w.hash.Write(b[:n])
+	}
+	return n, err
+}
+
+/*
+Make compression decision, set response headers and send delayed status and buffered body. Strong ETag of compressed response is changed to weak one. If client forbids uncompressed response ("identity;q=0") and response body is not compressed or encoded by handler, 406 Not Acceptable status is sent instead
+*/
+func (w *gzipResponseWriter) start(compress bool) error {
+	if compress && w.enc == nil && !w.varyOnly {
+		// client does not accept gzip (or gzip writer can not be created)
+		compress = false
+	}
+	if compress && !w.force && !w.compressible() {
+		compress = false
+	}
+	if compress && w.Header().Get("Content-Range") != "" {
+		// range offsets describe uncompressed body, so partial response must not be compressed
+		compress = false
+	}
+	if compress && alreadyEncoded(w.Header()) {
+		// body is encoded by handler (for example, precompressed file), so it must not be encoded twice
+		compress = false
+	}
+	if compress && w.varyOnly {
+		// client does not accept gzip, but the same response is compressed for clients, which accept it, so caches must not share it
+		addVary(w.Header(), "Accept-Encoding")
+		compress = false
+	}
+	w.decided = true
+	w.passthrough = !compress
+	if !compress && w.force && !alreadyEncoded(w.Header()) && (w.status == 0 || bodyAllowedForStatus(w.status)) {
+		w.reject()
+		return nil
+	}
+	if compress {
+		h := w.Header()
+		h.Set("Content-Encoding", w.encoding)
+		addVary(h, "Accept-Encoding")
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// compressed and uncompressed representations must not share strong ETag
+			h.Set("ETag", "W/"+etag)
+		}
+		if GzipTrailerChecksum {
+			h.Add("Trailer", checksumTrailer)
+			w.hash = sha256.New()
+		}
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.buf == nil {
+		return nil
+	}
+	buf := w.buf
+	w.buf = nil
+	defer putBuffer(buf)
+	_, err := w.write(buf.Bytes())
+	return err
+}
+
+/*
+Send 406 Not Acceptable status instead of uncompressed response (body written by handler is discarded)
+*/
+func (w *gzipResponseWriter) reject() {
+	w.rejected = true
+	if w.buf != nil {
+		putBuffer(w.buf)
+		w.buf = nil
+	}
+	h := w.Header()
+	for _, name := range []string{"Content-Length", "Content-Disposition", "ETag", "Last-Modified", "Accept-Ranges"} {
+		h.Del(name)
+	}
+	http.Error(w.ResponseWriter, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+}
+
+/*
+Get Content-Length header value set by handler (-1 if it is not set or invalid)
+*/
+func (w *gzipResponseWriter) contentLength() int64 {
+	length, err := strconv.ParseInt(w.Header().Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return -1
+	}
+	return length
+}
+
+func (w *gzipResponseWriter) bufferedLen() int {
+	if w.buf == nil {
+		return 0
+	}
+	return w.buf.Len()
+}
+
+/*
+Check if response should be compressed (client allows uncompressed response)
+*/
+func (w *gzipResponseWriter) compressible() bool {
+	if len(GzipStatusCodes) > 0 && !containsStatus(GzipStatusCodes, w.status) {
+		return false
+	}
+	if length := w.contentLength(); length >= 0 {
+		if GzipMinLength > 0 {
+			// Content-Length is used as body size, so response is not buffered
+			if length < int64(GzipMinLength) {
+				return false
+			}
+		} else if !w.streaming {
+			// handler knows exact body size, so Content-Length must stay valid
+			return false
+		}
+	}
+	return !excludedContentType(w.Header())
+}
+
+/*
+Check if Content-Type of response is listed in GzipExcludedContentTypes
+*/
+func excludedContentType(h http.Header) bool {
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	for _, excluded := range GzipExcludedContentTypes {
+		if strings.HasPrefix(contentType, strings.ToLower(excluded)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Check if status (0 means 200 OK, because WriteHeader was not called) is listed in statuses
+*/
+func containsStatus(statuses []int, status int) bool {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Flush gzip stream to client, set checksum trailer (if GzipTrailerChecksum is true) and call OnGzipStats callback (if set). Only the first call closes gzip stream (AfterResponse hook closes it before ServeHTTP). Empty responses are sent uncompressed, responses smaller than GzipMinLength are sent uncompressed too (if client allows it)
+*/
+func (w *gzipResponseWriter) Close() error {
+	if w.closed {
+		return w.closeErr
+	}
+	w.closed = true
+	w.closeErr = w.close()
+	return w.closeErr
+}
+
+func (w *gzipResponseWriter) close() error {
+	if !w.decided {
+		if w.force && w.bufferedLen() == 0 {
+			// empty body has no content coding, so it is acceptable with "identity;q=0" too
+			w.force = false
+		}
+		if err := w.start(w.force); err != nil {
+			return err
+		}
+	}
+	if w.passthrough {
+		return nil
+	}
+	err := w.enc.Close()
+	if w.hash != nil {
+		w.Header().Set(checksumTrailer, hex.EncodeToString(w.hash.Sum(nil)))
+	}
+	if OnGzipStats != nil {
+		OnGzipStats(w.uncompressed, w.compressed)
+	}
+	return err
+}
+
+/*
+Send buffered data to client (http.Flusher implementation)
+*/
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+	}
+	if !w.passthrough {
+		w.enc.Flush()
+	}
+	flushResponse(w.ResponseWriter)
+}
+
+/*
+Take over connection of underlying http.ResponseWriter (http.Hijacker implementation, for example, for WebSocket upgrade). Response is not compressed after connection is hijacked
+*/
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.decided = true
+		w.passthrough = true
+	}
+	return conn, rw, err
+}
+
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type gzipWriterKey struct{}
+
+type gzipOwnerKey struct{}
+
+/*
+Get gzipResponseWriter, if w is created by ServeHTTP call, which called handler with w directly (nil if w is wrapped or it belongs to enclosing handler, because only its owner can finish gzip stream)
+*/
+func ownedGzipResponseWriter(w http.ResponseWriter, r *http.Request) *gzipResponseWriter {
+	gzr, ok := w.(*gzipResponseWriter)
+	if !ok || r.Context().Value(gzipOwnerKey{}) != gzr {
+		return nil
+	}
+	return gzr
+}
+
+/*
+Get request without gzipResponseWriter owner, so nested handlers do not finish gzip stream of enclosing handler
+*/
+func withoutGzipOwner(r *http.Request) *http.Request {
+	if r.Context().Value(gzipOwnerKey{}) == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), gzipOwnerKey{}, nil))
+}
+
+/*
+Check if response of request is compressed by ServeHTTP: gzip is accepted by client and not disabled (for example, by GzipPathMatcher, GzipExcludedContentTypes or GzipMinLength). Before first Write, true means response will be compressed, if decision is not changed by response headers
+*/
+func GzipActive(r *http.Request) bool {
+	gzr, ok := r.Context().Value(gzipWriterKey{}).(*gzipResponseWriter)
+	return ok && gzr.enc != nil && !(gzr.decided && gzr.passthrough)
+}
+
+/*
+Get gzipResponseWriter, if w is (or wraps) it
+*/
+func findGzipResponseWriter(w http.ResponseWriter) *gzipResponseWriter {
+	for {
+		switch t := w.(type) {
+		case *gzipResponseWriter:
+			return t
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+/*
+Flush w, if it (or ResponseWriter wrapped by it) implements http.Flusher
+*/
+func flushResponse(w http.ResponseWriter) {
+	for {
+		switch t := w.(type) {
+		case http.Flusher:
+			t.Flush()
+			return
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = t.Unwrap()
+		default:
+			return
+		}
+	}
+}
+
+/*
+Disable gzip compression, if w is (or wraps) gzipResponseWriter. Use it when response body does not need compression (responses with Content-Encoding header are never compressed twice anyway). If client forbids uncompressed response ("identity;q=0"), response is compressed anyway, so it is not rejected with 406 Not Acceptable status. Must be called before first Write, but after response headers are set
+*/
+func disableGzip(w http.ResponseWriter) {
+	if gzr := findGzipResponseWriter(w); gzr != nil && !gzr.decided && !gzr.force {
+		gzr.start(false)
+	}
+}
+
+/*
+By default, Http response is not compressed if handler sets Content-Length header (so it stays valid). Call EnableGzipStreaming before first Write to compress such response anyway (Content-Length header is removed and response is streamed)
+*/
+func EnableGzipStreaming(w http.ResponseWriter) {
+	if gzr := findGzipResponseWriter(w); gzr != nil {
+		gzr.streaming = true
+	}
+}
+
+/*
+Check if Content-Encoding header (other than identity) is already set
+*/
+func alreadyEncoded(h http.Header) bool {
+	encoding := strings.TrimSpace(h.Get("Content-Encoding"))
+	return encoding != "" && !strings.EqualFold(encoding, "identity")
+}
+
+/*
+Add field to Vary header, if it is not listed yet
+*/
+func addVary(h http.Header, field string) {
+	for _, value := range h.Values("Vary") {
+		for _, f := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
+
+/*
+Check if response with status can contain body (RFC 7230)
+*/
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,553 @@
+package webimizer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func gunzip(t *testing.T, body []byte) string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("response is not gzip compressed: %v", err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+/*
+Set package level variable for test duration
+*/
+func setForTest[T any](t testing.TB, v *T, value T) {
+	t.Helper()
+	old := *v
+	*v = value
+	t.Cleanup(func() { *v = old })
+}
+
+func TestIdentityForbiddenDenyListedType(t *testing.T) {
+	setForTest(t, &GzipExcludedContentTypes, []string{"image/"})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "image/svg+xml")
+		io.WriteString(rw, "<svg></svg>")
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"identity;q=0, gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want forced gzip", rec.Header().Get("Content-Encoding"))
+	}
+	if got := gunzip(t, rec.Body.Bytes()); got != "<svg></svg>" {
+		t.Errorf("body = %q", got)
+	}
+	// without identity;q=0 deny-listed type is not compressed
+	rec = serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "<svg></svg>" {
+		t.Errorf("deny-listed type got Content-Encoding %q, body %q", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+}
+
+func TestIdentityForbiddenWithoutGzip(t *testing.T) {
+	called := false
+	plain := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		called = true
+		rw.Header().Set("Content-Length", "5")
+		io.WriteString(rw, "plain")
+	})
+	rec := serveRequest(plain, http.MethodGet, "/", http.Header{"Accept-Encoding": {"br, identity;q=0"}})
+	if !called {
+		t.Error("handler is not called")
+	}
+	if rec.Code != http.StatusNotAcceptable || strings.Contains(rec.Body.String(), "plain") {
+		t.Errorf("uncompressed response got %d %q, want 406", rec.Code, rec.Body.String())
+	}
+
+	brotli := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Encoding", "br")
+		io.WriteString(rw, "brotli data")
+	})
+	rec = serveRequest(brotli, http.MethodGet, "/", http.Header{"Accept-Encoding": {"br, identity;q=0"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli data" {
+		t.Errorf("br response got %d %q %q, want 200 br", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+
+	noContent := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	rec = serveRequest(noContent, http.MethodGet, "/", http.Header{"Accept-Encoding": {"br, identity;q=0"}})
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("204 response got %d", rec.Code)
+	}
+}
+
+func TestDisableGzipIdentityForbidden(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		disableGzip(rw)
+		io.WriteString(rw, r.URL.Query().Get("body"))
+	})
+	rec := serveRequest(handler, http.MethodGet, "/?body=data", http.Header{"Accept-Encoding": {"gzip, identity;q=0"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" || gunzip(t, rec.Body.Bytes()) != "data" {
+		t.Errorf("got %d %q, want compressed 200", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	rec = serveRequest(handler, http.MethodGet, "/?body=data", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "data" {
+		t.Errorf("gzip client got Content-Encoding %q, want uncompressed", rec.Header().Get("Content-Encoding"))
+	}
+	// empty body has no content coding
+	for _, acceptEncoding := range []string{"gzip, identity;q=0", "br, identity;q=0"} {
+		rec = serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {acceptEncoding}})
+		if rec.Code != http.StatusOK || rec.Body.Len() != 0 || rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%q: empty response got %d %q with %d bytes, want empty 200", acceptEncoding, rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.Len())
+		}
+	}
+}
+
+func TestIdentityForbiddenPrecompressedBrotli(t *testing.T) {
+	handler := FileServerStruct{
+		FileSystem:          newMemoryFileSystem(map[string][]byte{"/app.js": []byte("plain"), "/app.js.br": []byte("brotli")}),
+		PrecompressedBrotli: true,
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/app.js", http.Header{"Accept-Encoding": {"br, identity;q=0"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != "brotli" {
+		t.Errorf("got %d %q %q, want precompressed br file", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+}
+
+/*
+Handler, which copies content to response with io.Copy (if hideReaderFrom is true, io.ReaderFrom of response writer is not used)
+*/
+func copyHandler(content []byte, hideReaderFrom bool) HttpHandler {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		var dst io.Writer = rw
+		if hideReaderFrom {
+			dst = struct{ io.Writer }{rw}
+		}
+		// source without io.WriterTo, so io.Copy uses ReadFrom of dst, if it exists
+		io.Copy(dst, struct{ io.Reader }{bytes.NewReader(content)})
+	}
+}
+
+func TestGzipReadFrom(t *testing.T) {
+	content := bytes.Repeat([]byte("webimizer io.Copy "), 10000)
+	for _, hide := range []bool{false, true} {
+		rec := serveRequest(copyHandler(content, hide), http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+		if got := gunzip(t, rec.Body.Bytes()); got != string(content) {
+			t.Errorf("hideReaderFrom=%v: decompressed body differs from content (%d of %d bytes)", hide, len(got), len(content))
+		}
+	}
+
+	rec := serveRequest(copyHandler(content, false), http.MethodGet, "/", nil)
+	if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), content) {
+		t.Error("uncompressed response body differs from content")
+	}
+}
+
+func BenchmarkGzipCopy(b *testing.B) {
+	content := bytes.Repeat([]byte("webimizer io.Copy "), 10000)
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	for _, bench := range []struct {
+		name           string
+		hideReaderFrom bool
+	}{
+		{"ReadFrom", false},
+		{"Write", true},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			handler := copyHandler(content, bench.hideReaderFrom)
+			b.SetBytes(int64(len(content)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				handler.ServeHTTP(&discardResponseWriter{header: http.Header{}}, r)
+			}
+		})
+	}
+}
+
+func TestOnGzipStats(t *testing.T) {
+	var calls int
+	var uncompressed, compressed int64
+	setForTest(t, &OnGzipStats, func(u, c int64) {
+		calls++
+		uncompressed, compressed = u, c
+	})
+	content := strings.Repeat("compressible content ", 500)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, content)
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if gunzip(t, rec.Body.Bytes()) != content {
+		t.Fatal("decompressed body differs from content")
+	}
+	if calls != 1 {
+		t.Fatalf("OnGzipStats called %d times, want 1", calls)
+	}
+	if uncompressed != int64(len(content)) {
+		t.Errorf("uncompressed = %d, want %d", uncompressed, len(content))
+	}
+	if compressed != int64(rec.Body.Len()) {
+		t.Errorf("compressed = %d, want %d (response body size)", compressed, rec.Body.Len())
+	}
+
+	calls = 0
+	serveRequest(handler, http.MethodGet, "/", nil)
+	if calls != 0 {
+		t.Error("OnGzipStats called for uncompressed response")
+	}
+}
+
+func TestInvalidGzipCompressionLevel(t *testing.T) {
+	setForTest(t, &GzipCompressionLevel, 42)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "plain content")
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "plain content" {
+		t.Errorf("got %d %q %q, want uncompressed response without Content-Encoding", rec.Code, rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+}
+
+func TestVaryOnlyWhenCompressed(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, r.URL.Query().Get("body"))
+	})
+	rec := serveRequest(handler, http.MethodGet, "/?body=small", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "small" {
+		t.Fatalf("small response got %q %q, want uncompressed", rec.Header().Get("Content-Encoding"), rec.Body.String())
+	}
+	if vary := rec.Header().Values("Vary"); len(vary) != 0 {
+		t.Errorf("small uncompressed response Vary = %q, want absent", vary)
+	}
+
+	large := strings.Repeat("a", 2048)
+	rec = serveRequest(handler, http.MethodGet, "/?body="+large, http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("large response got Content-Encoding %q Vary %q, want gzip with Vary: Accept-Encoding", rec.Header().Get("Content-Encoding"), rec.Header().Get("Vary"))
+	}
+}
+
+func TestVaryWithoutGzip(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image":
+			rw.Header().Set("Content-Type", "image/png")
+		case "/error":
+			rw.WriteHeader(http.StatusInternalServerError)
+		case "/disabled":
+			disableGzip(rw)
+		case "/small":
+			io.WriteString(rw, "small")
+			return
+		}
+		io.WriteString(rw, strings.Repeat("page content ", 200))
+	})
+	setForTest(t, &GzipExcludedContentTypes, []string{"image/"})
+	setForTest(t, &GzipMinLength, 1024)
+	tests := []struct {
+		path string
+		vary string
+	}{
+		{"/page", "Accept-Encoding"},
+		{"/image", ""},
+		{"/error", ""},
+		{"/disabled", ""},
+		{"/small", ""},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(handler, http.MethodGet, tt.path, nil)
+		if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != tt.vary {
+			t.Errorf("%s without gzip got Content-Encoding %q Vary %q, want uncompressed with Vary %q", tt.path, rec.Header().Get("Content-Encoding"), rec.Header().Get("Vary"), tt.vary)
+		}
+	}
+
+	content := []byte(strings.Repeat("body { color: red }\n", 100))
+	files := FileServerStruct{FileSystem: newMemoryFileSystem(map[string][]byte{"/app.css": content})}.Build()
+	rec := serveRequest(files, http.MethodGet, "/app.css", http.Header{"Accept-Encoding": {"br;q=0"}})
+	if rec.Header().Get("Vary") != "Accept-Encoding" || !bytes.Equal(rec.Body.Bytes(), content) {
+		t.Errorf("file without gzip got Vary %q, want Accept-Encoding with full content", rec.Header().Get("Vary"))
+	}
+}
+
+/*
+httptest.ResponseRecorder, which implements http.Hijacker
+*/
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	server, client := net.Pipe()
+	client.Close()
+	return server, bufio.NewReadWriter(bufio.NewReader(server), bufio.NewWriter(server)), nil
+}
+
+func TestGzipHijack(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		hijacker, ok := rw.(http.Hijacker)
+		if !ok {
+			t.Fatalf("%T does not implement http.Hijacker", rw)
+		}
+		conn, _, err := hijacker.Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	})
+	for _, acceptEncoding := range []string{"", "gzip"} {
+		rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		if acceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		handler.ServeHTTP(rec, r)
+		if !rec.hijacked || rec.Body.Len() != 0 || rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("Accept-Encoding %q: hijacked %v, body %d bytes, Content-Encoding %q, want hijacked connection without response", acceptEncoding, rec.hijacked, rec.Body.Len(), rec.Header().Get("Content-Encoding"))
+		}
+	}
+}
+
+func TestGzipTrailerChecksum(t *testing.T) {
+	setForTest(t, &GzipTrailerChecksum, true)
+	content := strings.Repeat("checksum content ", 1000)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, content[:len(content)/2])
+		io.WriteString(rw, content[len(content)/2:])
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	res := rec.Result()
+	if got := res.Header.Get("Trailer"); got != checksumTrailer {
+		t.Errorf("Trailer = %q, want %q", got, checksumTrailer)
+	}
+	if gunzip(t, rec.Body.Bytes()) != content {
+		t.Fatal("decompressed body differs from content")
+	}
+	sum := sha256.Sum256([]byte(content))
+	if got := res.Trailer.Get(checksumTrailer); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("%s trailer = %q, want %x", checksumTrailer, got, sum)
+	}
+}
+
+func TestGzipSniffContentType(t *testing.T) {
+	png := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "\x89PNG\r\n\x1a\n custom binary format")
+	})
+	r, _ := http.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	tests := []struct {
+		name        string
+		sniff       bool
+		defaultType string
+		wantType    string
+	}{
+		{"enabled", true, "", "image/png"},
+		{"disabled", false, "", ""},
+		{"disabled with default", false, "application/octet-stream", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setForTest(t, &GzipSniffContentType, tt.sniff)
+			setForTest(t, &GzipDefaultContentType, tt.defaultType)
+			// discardResponseWriter does not sniff content type itself, like net/http for responses with Content-Encoding
+			w := &discardResponseWriter{header: http.Header{}}
+			png.ServeHTTP(w, r)
+			if w.header.Get("Content-Encoding") != "gzip" {
+				t.Fatal("response is not compressed")
+			}
+			if got := w.header.Get("Content-Type"); got != tt.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantType)
+			}
+		})
+	}
+}
+
+func TestContentLengthSkipsGzip(t *testing.T) {
+	content := strings.Repeat("exact size content ", 100)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("streaming") != "" {
+			EnableGzipStreaming(rw)
+		}
+		rw.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		io.WriteString(rw, content)
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != content {
+		t.Errorf("got Content-Encoding %q with %d body bytes, want uncompressed content", rec.Header().Get("Content-Encoding"), rec.Body.Len())
+	}
+	if got := rec.Header().Get("Content-Length"); got != strconv.Itoa(len(content)) {
+		t.Errorf("Content-Length = %q, want %d", got, len(content))
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/?streaming=1", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("streaming got Content-Encoding %q Content-Length %q, want gzip without Content-Length", rec.Header().Get("Content-Encoding"), rec.Header().Get("Content-Length"))
+	}
+	if gunzip(t, rec.Body.Bytes()) != content {
+		t.Error("decompressed streaming body differs from content")
+	}
+}
+
+func TestGzipMinLengthContentLength(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	tests := []struct {
+		name       string
+		size       int
+		compressed bool
+	}{
+		{"small", 100, false},
+		{"large", 4096, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := strings.Repeat("a", tt.size)
+			rec := httptest.NewRecorder()
+			handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				io.WriteString(rw, content[:10])
+				rw.(http.Flusher).Flush()
+				// decision is made by Content-Length, so first chunk is not buffered
+				if rec.Body.Len() == 0 {
+					t.Error("first chunk is buffered")
+				}
+				io.WriteString(rw, content[10:])
+			})
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", "gzip")
+			handler.ServeHTTP(rec, r)
+			if compressed := rec.Header().Get("Content-Encoding") == "gzip"; compressed != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.compressed)
+			}
+			if tt.compressed {
+				if rec.Header().Get("Content-Length") != "" || gunzip(t, rec.Body.Bytes()) != content {
+					t.Errorf("compressed response has Content-Length %q or wrong body", rec.Header().Get("Content-Length"))
+				}
+			} else if rec.Header().Get("Content-Length") != strconv.Itoa(tt.size) || rec.Body.String() != content {
+				t.Errorf("uncompressed response has Content-Length %q or wrong body", rec.Header().Get("Content-Length"))
+			}
+		})
+	}
+}
+
+func TestHandlerContentEncodingNotEncodedAgain(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Encoding", "br")
+		rw.Header().Set("Content-Type", "text/plain")
+		io.WriteString(rw, "brotli encoded data")
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip, br"}})
+	if got := rec.Header().Values("Content-Encoding"); len(got) != 1 || got[0] != "br" {
+		t.Errorf("Content-Encoding = %q, want br only", got)
+	}
+	if rec.Body.String() != "brotli encoded data" {
+		t.Errorf("body = %q, want handler body unchanged", rec.Body.String())
+	}
+}
+
+func TestGzipActive(t *testing.T) {
+	var before, after bool
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		before = GzipActive(r)
+		if r.URL.Query().Get("disable") != "" {
+			disableGzip(rw)
+		}
+		io.WriteString(rw, "content")
+		after = GzipActive(r)
+	})
+	tests := []struct {
+		name           string
+		target         string
+		acceptEncoding string
+		before, after  bool
+	}{
+		{"gzip", "/", "gzip", true, true},
+		{"no gzip", "/", "", false, false},
+		{"gzip not accepted", "/", "br, gzip;q=0", false, false},
+		{"gzip disabled by handler", "/?disable=1", "gzip", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serveRequest(handler, http.MethodGet, tt.target, http.Header{"Accept-Encoding": {tt.acceptEncoding}})
+			if before != tt.before || after != tt.after {
+				t.Errorf("GzipActive() before write %v, after write %v, want %v %v", before, after, tt.before, tt.after)
+			}
+		})
+	}
+	if GzipActive(httptest.NewRequest(http.MethodGet, "/", nil)) {
+		t.Error("GzipActive() is true for request without ServeHTTP")
+	}
+}
+
+func TestGzipStatusCodes(t *testing.T) {
+	page := strings.Repeat("page content ", 200)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		status, _ := strconv.Atoi(r.URL.Query().Get("status"))
+		rw.WriteHeader(status)
+		io.WriteString(rw, page)
+	})
+	tests := []struct {
+		status   int
+		encoding string
+	}{
+		{http.StatusOK, "gzip"},
+		{http.StatusCreated, "gzip"},
+		{http.StatusNonAuthoritativeInfo, "gzip"},
+		{http.StatusPartialContent, ""},
+		{http.StatusInternalServerError, ""},
+		{http.StatusNotFound, ""},
+		{http.StatusBadRequest, ""},
+	}
+	for _, test := range tests {
+		rec := serveRequest(handler, http.MethodGet, "/?status="+strconv.Itoa(test.status), http.Header{"Accept-Encoding": {"gzip"}})
+		if rec.Code != test.status || rec.Header().Get("Content-Encoding") != test.encoding {
+			t.Errorf("status %d got %d with Content-Encoding %q, want %q", test.status, rec.Code, rec.Header().Get("Content-Encoding"), test.encoding)
+			continue
+		}
+		body := rec.Body.String()
+		if test.encoding == "gzip" {
+			body = gunzip(t, rec.Body.Bytes())
+		}
+		if body != page {
+			t.Errorf("status %d body is %d bytes, want page", test.status, len(body))
+		}
+	}
+}
+
+func TestGzipStatusCodesEmpty(t *testing.T) {
+	setForTest(t, &GzipStatusCodes, nil)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(rw, "error page")
+	})
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" || gunzip(t, rec.Body.Bytes()) != "error page" {
+		t.Errorf("empty GzipStatusCodes: 500 got Content-Encoding %q, want gzip", rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestGzipContentRange(t *testing.T) {
+	setForTest(t, &GzipStatusCodes, nil)
+	content := []byte(strings.Repeat("0123456789", 200))
+	handler := FileServerStruct{FileSystem: newMemoryFileSystem(map[string][]byte{"/data.txt": content})}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/data.txt", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=0-9"}})
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("range request got %d with Content-Encoding %q, want uncompressed 206", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Body.String() != "0123456789" || rec.Header().Get("Content-Range") != "bytes 0-9/2000" {
+		t.Errorf("range request got %q with Content-Range %q, want first 10 bytes", rec.Body.String(), rec.Header().Get("Content-Range"))
+	}
+}
@@ -0,0 +1,62 @@
+package webimizer
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+/*
+Read default Http Response headers from config file and replace DefaultHTTPHeaders. Each line of file is "Header-Name: value", empty lines and lines starting with # are ignored
+Example:
+
+	# security headers
+	X-Content-Type-Options: nosniff
+	X-Frame-Options: SAMEORIGIN
+*/
+func LoadHeadersFile(path string) error {
+	headers, err := parseHeadersFile(path)
+	if err != nil {
+		return err
+	}
+	SetDefaultHTTPHeaders(headers)
+	return nil
+}
+
+/*
+Load default Http Response headers from config file (see LoadHeadersFile) and reload them on SIGHUP signal without restarting server, until process exits. If file can not be read on reload, previous headers are kept and error is logged (use WatchHeadersFileFunc to handle reload errors or to stop watching)
+*/
+func WatchHeadersFile(path string) error {
+	_, err := WatchHeadersFileFunc(path, func(err error) {
+		log.Printf("webimizer: reloading headers file: %v", err)
+	})
+	return err
+}
+
+func parseHeadersFile(path string) ([][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var headers [][]string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		kv := strings.SplitN(text, ":", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" {
+			return nil, fmt.Errorf("webimizer: %s:%d: invalid header line", path, line)
+		}
+		headers = append(headers, []string{key, strings.TrimSpace(kv[1])})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
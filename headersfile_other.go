@@ -0,0 +1,13 @@
+//go:build js || wasip1
+
+package webimizer
+
+/*
+Load default Http Response headers from config file (see LoadHeadersFile). Signals are not supported on this platform, so headers are not reloaded and onError is never called. Returned stop func does nothing
+*/
+func WatchHeadersFileFunc(path string, onError func(error)) (stop func(), err error) {
+	if err := LoadHeadersFile(path); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
@@ -0,0 +1,50 @@
+//go:build !js && !wasip1
+
+package webimizer
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+/*
+Load default Http Response headers from config file (see LoadHeadersFile) and reload them on SIGHUP signal without restarting server. If file can not be read on reload, previous headers are kept and error is passed to onError (optional). Returned stop func stops watching for signals
+Example:
+
+	stop, err := webimizer.WatchHeadersFileFunc("headers.conf", func(err error) {
+		log.Printf("headers reload failed: %v", err)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer stop()
+*/
+func WatchHeadersFileFunc(path string, onError func(error)) (stop func(), err error) {
+	if err := LoadHeadersFile(path); err != nil {
+		return nil, err
+	}
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := LoadHeadersFile(path); err != nil && onError != nil {
+					onError(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(signals)
+			close(done)
+		})
+	}, nil
+}
@@ -0,0 +1,78 @@
+//go:build !js && !wasip1
+
+package webimizer
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatchHeadersFile(t *testing.T) {
+	setForTest(t, &DefaultHTTPHeaders, nil)
+	path := filepath.Join(t.TempDir(), "headers.conf")
+	writeTestFile(t, filepath.Dir(path), filepath.Base(path), "# security headers\nX-Frame-Options: DENY\n\nX-Config-Version: 1\n")
+	errs := make(chan error, 1)
+	stop, err := WatchHeadersFileFunc(path, func(err error) { errs <- err })
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {})
+	rec := serveRequest(handler, http.MethodGet, "/", nil)
+	if rec.Header().Get("X-Frame-Options") != "DENY" || rec.Header().Get("X-Config-Version") != "1" {
+		t.Fatalf("headers = %v, want headers from file", rec.Header())
+	}
+
+	writeTestFile(t, filepath.Dir(path), filepath.Base(path), "X-Frame-Options: SAMEORIGIN\nX-Config-Version: 2\n")
+	p, _ := os.FindProcess(os.Getpid())
+	if err := p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("SIGHUP can not be sent: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec = serveRequest(handler, http.MethodGet, "/", nil)
+		if rec.Header().Get("X-Config-Version") == "2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("headers are not reloaded on SIGHUP: %v", rec.Header())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := rec.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Errorf("X-Frame-Options = %q after reload, want SAMEORIGIN", got)
+	}
+
+	// invalid file is reported and previous headers are kept
+	writeTestFile(t, filepath.Dir(path), filepath.Base(path), "X-Config-Version 3\n")
+	p.Signal(syscall.SIGHUP)
+	select {
+	case err := <-errs:
+		if err == nil {
+			t.Error("onError called with nil error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reload error is not reported")
+	}
+	if got := serveRequest(handler, http.MethodGet, "/", nil).Header().Get("X-Config-Version"); got != "2" {
+		t.Errorf("X-Config-Version = %q after failed reload, want 2", got)
+	}
+
+	// signal is caught by test after stop, so process is not terminated
+	caught := make(chan os.Signal, 1)
+	signal.Notify(caught, syscall.SIGHUP)
+	defer signal.Stop(caught)
+	stop()
+	writeTestFile(t, filepath.Dir(path), filepath.Base(path), "X-Config-Version: 4\n")
+	p.Signal(syscall.SIGHUP)
+	<-caught
+	time.Sleep(20 * time.Millisecond)
+	if got := serveRequest(handler, http.MethodGet, "/", nil).Header().Get("X-Config-Version"); got != "2" {
+		t.Errorf("X-Config-Version = %q after stop, want 2", got)
+	}
+}
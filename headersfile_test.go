@@ -0,0 +1,27 @@
+package webimizer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadHeadersFileInvalid(t *testing.T) {
+	setForTest(t, &DefaultHTTPHeaders, [][]string{{"X-Frame-Options", "DENY"}})
+	dir := t.TempDir()
+	writeTestFile(t, dir, "headers.conf", "X-Frame-Options SAMEORIGIN\n")
+	if err := LoadHeadersFile(filepath.Join(dir, "headers.conf")); err == nil {
+		t.Error("invalid header line is accepted")
+	}
+	if err := LoadHeadersFile(filepath.Join(dir, "missing.conf")); err == nil {
+		t.Error("missing file is accepted")
+	}
+	if len(DefaultHTTPHeaders) != 1 || DefaultHTTPHeaders[0][1] != "DENY" {
+		t.Errorf("DefaultHTTPHeaders = %v, want previous headers kept", DefaultHTTPHeaders)
+	}
+}
+
+func TestWatchHeadersFileMissing(t *testing.T) {
+	if err := WatchHeadersFile(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Error("missing file is accepted")
+	}
+}
@@ -0,0 +1,51 @@
+package webimizer
+
+import (
+	"net/http"
+)
+
+/*
+http.ResponseWriter, which calls hook once just before response headers are sent (so hook can change headers set by handler)
+*/
+type headerHookWriter struct {
+	http.ResponseWriter
+	hook func(http.Header)
+	done bool
+}
+
+/*
+Call handler with headerHookWriter. Hook is also called after handler returns, if handler did not write anything
+*/
+func serveWithHeaderHook(rw http.ResponseWriter, r *http.Request, handler HttpHandler, hook func(http.Header)) {
+	hw := &headerHookWriter{ResponseWriter: rw, hook: hook}
+	handler(hw, r)
+	hw.runHook()
+}
+
+func (w *headerHookWriter) runHook() {
+	if !w.done {
+		w.done = true
+		w.hook(w.Header())
+	}
+}
+
+func (w *headerHookWriter) WriteHeader(status int) {
+	if status >= 200 {
+		w.runHook()
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerHookWriter) Write(b []byte) (int, error) {
+	w.runHook()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headerHookWriter) Flush() {
+	w.runHook()
+	flushResponse(w.ResponseWriter)
+}
+
+func (w *headerHookWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
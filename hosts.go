@@ -0,0 +1,133 @@
+package webimizer
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+Get request host without port (lower case)
+*/
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+/*
+Check if host matches pattern: exact host name or wildcard (for example, "*.example.com" matches any subdomain of example.com, but not example.com itself)
+*/
+func matchHost(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	if strings.HasPrefix(pattern, "*.") {
+		return strings.HasSuffix(host, pattern[1:]) && len(host) > len(pattern)-1
+	}
+	return pattern == host
+}
+
+/*
+Reject requests, whose Host header (without port) does not match any of hosts (exact host name or wildcard like "*.example.com"), with 421 Misdirected Request status. It mitigates Host header attacks
+*/
+func (fn HttpHandler) WithAllowedHosts(hosts ...string) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		host := requestHost(r)
+		for _, pattern := range hosts {
+			if matchHost(pattern, host) {
+				fn(rw, r)
+				return
+			}
+		}
+		http.Error(rw, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+	})
+}
+
+/*
+Router, which dispatches requests by Host header (without port) to handlers. Use NewHostRouter to create it and Handle to register handlers. It implements http.Handler
+Example:
+
+	router := app.NewHostRouter()
+	router.Handle("a.example.com", aHandler)
+	router.Handle("*.example.com", tenantHandler)
+	router.Default = landingHandler
+	http.ListenAndServe(":8080", router)
+*/
+type HostRouter struct {
+	// Handler of requests to not registered hosts (if nil, 421 Misdirected Request status is sent)
+	Default HttpHandler
+
+	mu        sync.RWMutex
+	hosts     map[string]HttpHandler
+	wildcards []hostRoute
+}
+
+type hostRoute struct {
+	pattern string
+	handler HttpHandler
+}
+
+/*
+Create empty HostRouter
+*/
+func NewHostRouter() *HostRouter {
+	return &HostRouter{hosts: map[string]HttpHandler{}}
+}
+
+/*
+Register handler for host: exact host name or wildcard (for example, "*.example.com"). Exact host has precedence over wildcard, longer wildcard has precedence over shorter one. Previous handler of the same host is replaced
+*/
+func (router *HostRouter) Handle(host string, h HttpHandler) {
+	host = strings.ToLower(host)
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	if !strings.HasPrefix(host, "*.") {
+		router.hosts[host] = h
+		return
+	}
+	for i, route := range router.wildcards {
+		if route.pattern == host {
+			router.wildcards[i].handler = h
+			return
+		}
+	}
+	router.wildcards = append(router.wildcards, hostRoute{pattern: host, handler: h})
+	sort.SliceStable(router.wildcards, func(i, j int) bool {
+		return len(router.wildcards[i].pattern) > len(router.wildcards[j].pattern)
+	})
+}
+
+/*
+Get handler registered for host (nil if host does not match any registered host)
+*/
+func (router *HostRouter) handler(host string) HttpHandler {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	if h, ok := router.hosts[host]; ok {
+		return h
+	}
+	for _, route := range router.wildcards {
+		if matchHost(route.pattern, host) {
+			return route.handler
+		}
+	}
+	return nil
+}
+
+/*
+Serve request with handler of request host (or Default handler)
+*/
+func (router *HostRouter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	h := router.handler(requestHost(r))
+	if h == nil {
+		h = router.Default
+	}
+	if h == nil {
+		http.Error(rw, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+		return
+	}
+	h.ServeHTTP(rw, r)
+}
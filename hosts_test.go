@@ -0,0 +1,73 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serveHost(handler http.Handler, host string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = host
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	return rec
+}
+
+func TestWithAllowedHosts(t *testing.T) {
+	handler := HttpHandler(okHandler).WithAllowedHosts("example.com", "*.example.org")
+	tests := []struct {
+		host string
+		want int
+	}{
+		{"example.com", http.StatusOK},
+		{"EXAMPLE.com:8080", http.StatusOK},
+		{"api.example.org", http.StatusOK},
+		{"a.b.example.org", http.StatusOK},
+		{"example.org", http.StatusMisdirectedRequest},
+		{"evil.com", http.StatusMisdirectedRequest},
+		{"example.com.evil.com", http.StatusMisdirectedRequest},
+		{"evilexample.org", http.StatusMisdirectedRequest},
+		{"", http.StatusMisdirectedRequest},
+	}
+	for _, tt := range tests {
+		if rec := serveHost(handler, tt.host); rec.Code != tt.want {
+			t.Errorf("Host %q got %d, want %d", tt.host, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestHostRouter(t *testing.T) {
+	router := NewHostRouter()
+	router.Handle("a.example.com", methodNameHandler("a"))
+	router.Handle("B.example.com", methodNameHandler("b"))
+	router.Handle("*.example.com", methodNameHandler("tenant"))
+	router.Handle("*.eu.example.com", methodNameHandler("eu"))
+	tests := []struct {
+		host string
+		want string
+		code int
+	}{
+		{"a.example.com", "a", http.StatusOK},
+		{"b.example.com:8080", "b", http.StatusOK},
+		{"c.example.com", "tenant", http.StatusOK},
+		{"shop.eu.example.com", "eu", http.StatusOK},
+		{"example.com", "", http.StatusMisdirectedRequest},
+		{"other.org", "", http.StatusMisdirectedRequest},
+	}
+	for _, tt := range tests {
+		rec := serveHost(router, tt.host)
+		if rec.Code != tt.code || rec.Header().Get("X-Handler") != tt.want {
+			t.Errorf("Host %q got %d handled by %q, want %d %q", tt.host, rec.Code, rec.Header().Get("X-Handler"), tt.code, tt.want)
+		}
+	}
+
+	router.Default = methodNameHandler("default")
+	router.Handle("a.example.com", methodNameHandler("a2"))
+	if got := serveHost(router, "other.org").Header().Get("X-Handler"); got != "default" {
+		t.Errorf("unknown host handled by %q, want default", got)
+	}
+	if got := serveHost(router, "a.example.com").Header().Get("X-Handler"); got != "a2" {
+		t.Errorf("replaced host handled by %q, want a2", got)
+	}
+}
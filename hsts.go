@@ -0,0 +1,56 @@
+package webimizer
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+/*
+Default max-age of Strict-Transport-Security header (one year)
+*/
+const DefaultHSTSMaxAge = 365 * 24 * time.Hour
+
+/*
+Settings of RequireHTTPS: MaxAge of Strict-Transport-Security header (DefaultHSTSMaxAge, if it is 0), IncludeSubDomains and Preload directives (hstspreload.org requires both IncludeSubDomains and MaxAge of at least one year)
+*/
+type HTTPSOptions struct {
+	MaxAge            time.Duration
+	IncludeSubDomains bool
+	Preload           bool
+}
+
+/*
+Get Strict-Transport-Security header value
+*/
+func (opts HTTPSOptions) hstsValue() string {
+	maxAge := opts.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultHSTSMaxAge
+	}
+	value := "max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+	if opts.IncludeSubDomains {
+		value += "; includeSubDomains"
+	}
+	if opts.Preload {
+		value += "; preload"
+	}
+	return value
+}
+
+/*
+Create middleware, which redirects plaintext requests to https:// url (like WithRedirectToHTTPS) and sets Strict-Transport-Security header on responses of secure requests (RequestScheme is https)
+Example:
+
+	http.Handle("/", app.RequireHTTPS(app.HTTPSOptions{IncludeSubDomains: true})(handler))
+*/
+func RequireHTTPS(opts HTTPSOptions) func(HttpHandler) HttpHandler {
+	hsts := opts.hstsValue()
+	return func(fn HttpHandler) HttpHandler {
+		return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+			// HSTS header is ignored by browsers on plaintext responses, so it is set only for secure requests
+			rw.Header().Set("Strict-Transport-Security", hsts)
+			fn(rw, r)
+		}).WithRedirectToHTTPS()
+	}
+}
@@ -0,0 +1,46 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	handler := RequireHTTPS(HTTPSOptions{IncludeSubDomains: true})(okHandler)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "http://example.com/login?next=%2F", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://example.com/login?next=%2F" {
+		t.Errorf("plaintext request got %d %q, want redirect to https", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Error("HSTS header is set on plaintext response")
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "https://example.com/login", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("secure request got %d %q, want handler response", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Strict-Transport-Security"); got != "max-age=31536000; includeSubDomains" {
+		t.Errorf("Strict-Transport-Security = %q", got)
+	}
+}
+
+func TestHTTPSOptionsHSTSValue(t *testing.T) {
+	tests := []struct {
+		opts HTTPSOptions
+		want string
+	}{
+		{HTTPSOptions{}, "max-age=31536000"},
+		{HTTPSOptions{MaxAge: time.Hour}, "max-age=3600"},
+		{HTTPSOptions{MaxAge: 2 * DefaultHSTSMaxAge, IncludeSubDomains: true, Preload: true}, "max-age=63072000; includeSubDomains; preload"},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.hstsValue(); got != tt.want {
+			t.Errorf("%+v: got %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
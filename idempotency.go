@@ -0,0 +1,141 @@
+package webimizer
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/*
+Stored response of request with Idempotency-Key header
+*/
+type IdempotentResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+/*
+Storage of responses for WithIdempotency. Implementation must be safe for concurrent use
+*/
+type IdempotencyStore interface {
+	// Get stored response by key (false if it is not found or expired)
+	Get(key string) (*IdempotentResponse, bool)
+	// Store response by key
+	Set(key string, response *IdempotentResponse)
+}
+
+type memoryIdempotencyEntry struct {
+	response *IdempotentResponse
+	expires  time.Time
+}
+
+/*
+In-memory IdempotencyStore, which keeps responses for TTL duration
+*/
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]memoryIdempotencyEntry
+}
+
+/*
+Create in-memory IdempotencyStore, which keeps responses for ttl duration
+*/
+func NewMemoryIdempotencyStore(ttl time.Duration) *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{ttl: ttl, entries: map[string]memoryIdempotencyEntry{}}
+}
+
+func (s *MemoryIdempotencyStore) Get(key string) (*IdempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.entries, key)
+		return nil, false
+	}
+	return entry.response, true
+}
+
+func (s *MemoryIdempotencyStore) Set(key string, response *IdempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	// remove expired entries, so store does not grow forever
+	for k, entry := range s.entries {
+		if now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.entries[key] = memoryIdempotencyEntry{response: response, expires: now.Add(s.ttl)}
+}
+
+/*
+Make POST and PATCH requests with Idempotency-Key header safe to retry: response is stored in store and repeated request with the same key (and the same method and path) gets stored response (with Idempotent-Replayed: true header) instead of running handler again. 5xx responses are not stored, so such request can be retried. Concurrent request with the key, which is still being handled, gets 409 Conflict status. Value returned by scopeFn (for example, authenticated user ID) is part of the key, so stored response is replayed only to the same client (if scopeFn is nil, keys of all clients are shared). Set-Cookie headers are not stored or replayed
+Example:
+
+	handler.WithIdempotency(app.NewMemoryIdempotencyStore(24 * time.Hour), func(r *http.Request) string { return userID(r) })
+*/
+func (fn HttpHandler) WithIdempotency(store IdempotencyStore, scopeFn func(*http.Request) string) HttpHandler {
+	var mu sync.Mutex
+	inFlight := map[string]bool{}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		idempotencyKey := r.Header.Get("Idempotency-Key")
+		if idempotencyKey == "" || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+			fn(rw, r)
+			return
+		}
+		scope := ""
+		if scopeFn != nil {
+			scope = scopeFn(r)
+		}
+		// scope is length prefixed, so it can not be confused with method or path
+		key := strconv.Itoa(len(scope)) + ":" + scope + " " + r.Method + " " + r.URL.Path + " " + idempotencyKey
+		if stored, ok := store.Get(key); ok {
+			writeIdempotentResponse(rw, stored)
+			return
+		}
+		mu.Lock()
+		if inFlight[key] {
+			mu.Unlock()
+			http.Error(rw, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		inFlight[key] = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			delete(inFlight, key)
+			mu.Unlock()
+		}()
+		// request could be finished while waiting for lock
+		if stored, ok := store.Get(key); ok {
+			writeIdempotentResponse(rw, stored)
+			return
+		}
+		response := newCapturedResponse()
+		fn(response, r)
+		response.writeTo(rw)
+		if response.status < http.StatusInternalServerError {
+			header := response.header.Clone()
+			// session cookies of one client must not be sent to another
+			header.Del("Set-Cookie")
+			store.Set(key, &IdempotentResponse{
+				Status: response.status,
+				Header: header,
+				Body:   append([]byte(nil), response.body.Bytes()...),
+			})
+		}
+	})
+}
+
+func writeIdempotentResponse(rw http.ResponseWriter, stored *IdempotentResponse) {
+	response := &capturedResponse{header: stored.Header, status: stored.Status}
+	response.body.Write(stored.Body)
+	rw.Header().Set("Idempotent-Replayed", "true")
+	response.writeTo(rw)
+}
@@ -0,0 +1,78 @@
+package webimizer
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWithIdempotency(t *testing.T) {
+	executions := 0
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		executions++
+		rw.Header().Set("Location", fmt.Sprintf("/orders/%d", executions))
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(rw, "order %d", executions)
+	}).WithIdempotency(NewMemoryIdempotencyStore(time.Hour), nil)
+
+	first := serveRequest(handler, http.MethodPost, "/orders", http.Header{"Idempotency-Key": {"key-1"}})
+	second := serveRequest(handler, http.MethodPost, "/orders", http.Header{"Idempotency-Key": {"key-1"}})
+	if executions != 1 {
+		t.Fatalf("handler executed %d times, want 1", executions)
+	}
+	if first.Code != http.StatusCreated || first.Body.String() != "order 1" || first.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("first response got %d %q", first.Code, first.Body.String())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != "order 1" || second.Header().Get("Location") != "/orders/1" {
+		t.Errorf("replayed response got %d %q Location %q, want stored response", second.Code, second.Body.String(), second.Header().Get("Location"))
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("replayed response has no Idempotent-Replayed header")
+	}
+
+	serveRequest(handler, http.MethodPost, "/orders", http.Header{"Idempotency-Key": {"key-2"}})
+	serveRequest(handler, http.MethodPost, "/orders", nil)
+	if executions != 3 {
+		t.Errorf("handler executed %d times, want 3 (other key and request without key)", executions)
+	}
+}
+
+func TestWithIdempotencyScope(t *testing.T) {
+	executions := 0
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		executions++
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: r.Header.Get("X-User")})
+		fmt.Fprintf(rw, "order of %s", r.Header.Get("X-User"))
+	}).WithIdempotency(NewMemoryIdempotencyStore(time.Hour), func(r *http.Request) string { return r.Header.Get("X-User") })
+
+	alice := http.Header{"Idempotency-Key": {"key-1"}, "X-User": {"alice"}}
+	first := serveRequest(handler, http.MethodPost, "/orders", alice)
+	if first.Header().Get("Set-Cookie") == "" {
+		t.Error("first response has no Set-Cookie header")
+	}
+	replayed := serveRequest(handler, http.MethodPost, "/orders", alice)
+	if executions != 1 || replayed.Body.String() != "order of alice" {
+		t.Fatalf("handler executed %d times, replayed %q, want 1 and stored response", executions, replayed.Body.String())
+	}
+	if got := replayed.Header().Get("Set-Cookie"); got != "" {
+		t.Errorf("replayed response has Set-Cookie %q", got)
+	}
+
+	other := serveRequest(handler, http.MethodPost, "/orders", http.Header{"Idempotency-Key": {"key-1"}, "X-User": {"bob"}})
+	if executions != 2 || other.Body.String() != "order of bob" || other.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("other client got %q (handler executed %d times), want own response", other.Body.String(), executions)
+	}
+}
+
+func TestMemoryIdempotencyStoreTTL(t *testing.T) {
+	store := NewMemoryIdempotencyStore(10 * time.Millisecond)
+	store.Set("key", &IdempotentResponse{Status: http.StatusOK})
+	if _, ok := store.Get("key"); !ok {
+		t.Fatal("stored response is not found")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := store.Get("key"); ok {
+		t.Error("expired response is found")
+	}
+}
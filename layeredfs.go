@@ -0,0 +1,41 @@
+package webimizer
+
+import (
+	"net/http"
+	"os"
+)
+
+/*
+http.FileSystem, which looks up files in each filesystem in order and returns the first found
+*/
+type layeredFileSystem []http.FileSystem
+
+/*
+Create http Handler for serving files from several directories: file is looked up in each directory in order (for example, user overrides directory first, then defaults directory).
+If file not found in any directory return 404 status and serve error404.html if exist
+*/
+func NewLayeredFileServerHandler(dirs ...string) HttpHandler {
+	layers := make(layeredFileSystem, len(dirs))
+	for i, dir := range dirs {
+		layers[i] = http.Dir(dir)
+	}
+	return FileServerStruct{FileSystem: layers}.Build()
+}
+
+func (layers layeredFileSystem) Open(name string) (http.File, error) {
+	var firstErr error
+	for _, layer := range layers {
+		f, err := layer.Open(name)
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		// no layers
+		firstErr = os.ErrNotExist
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,31 @@
+package webimizer
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLayeredFileServerHandler(t *testing.T) {
+	overrides, defaults := filepath.Join(t.TempDir(), "overrides"), filepath.Join(t.TempDir(), "defaults")
+	writeTestFile(t, overrides, "style.css", "body{color:red}")
+	writeTestFile(t, defaults, "style.css", "body{}")
+	writeTestFile(t, defaults, "app.js", "default app")
+	writeTestFile(t, defaults, "error404.html", "default not found")
+	handler := NewLayeredFileServerHandler(overrides, defaults)
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/style.css", http.StatusOK, "body{color:red}"},
+		{"/app.js", http.StatusOK, "default app"},
+		{"/missing.js", http.StatusNotFound, "default not found"},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(handler, http.MethodGet, tt.path, nil)
+		if rec.Code != tt.status || rec.Body.String() != tt.body {
+			t.Errorf("%s got %d %q, want %d %q", tt.path, rec.Code, rec.Body.String(), tt.status, tt.body)
+		}
+	}
+}
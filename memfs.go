@@ -0,0 +1,150 @@
+package webimizer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+http.FileSystem, which serves files from memory. Directories are created implicitly from file paths
+*/
+type memoryFileSystem struct {
+	files map[string][]byte
+	etags map[string]string
+}
+
+/*
+Create http Handler for serving in-memory files (map key is file path, for example "/index.html", value is file content).
+If file not found return 404 status and serve /error404.html if exist
+*/
+func NewMemoryFileServerHandler(files map[string][]byte) HttpHandler {
+	return FileServerStruct{FileSystem: newMemoryFileSystem(files)}.Build()
+}
+
+func newMemoryFileSystem(files map[string][]byte) memoryFileSystem {
+	mfs := memoryFileSystem{files: make(map[string][]byte, len(files)), etags: make(map[string]string, len(files))}
+	for name, content := range files {
+		name = cleanPath(name)
+		mfs.files[name] = content
+		// content does not change, so ETag is computed once (HEAD requests do not read content)
+		mfs.etags[name] = contentHashETag(content)
+	}
+	return mfs
+}
+
+func (mfs memoryFileSystem) Open(name string) (http.File, error) {
+	name = cleanPath(name)
+	if content, ok := mfs.files[name]; ok {
+		return &memoryFile{Reader: bytes.NewReader(content), info: memoryFileInfo{name: path.Base(name), size: int64(len(content))}, etag: mfs.etags[name]}, nil
+	}
+	var entries []os.FileInfo
+	seen := map[string]bool{}
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	for filePath, content := range mfs.files {
+		if !strings.HasPrefix(filePath, prefix) {
+			continue
+		}
+		rest := filePath[len(prefix):]
+		entry := memoryFileInfo{name: rest, size: int64(len(content))}
+		if i := strings.Index(rest, "/"); i >= 0 {
+			entry = memoryFileInfo{name: rest[:i], dir: true}
+		}
+		if !seen[entry.name] {
+			seen[entry.name] = true
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) == 0 && name != "/" {
+		return nil, os.ErrNotExist
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &memoryFile{Reader: bytes.NewReader(nil), info: memoryFileInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+/*
+http.File implementation for memoryFileSystem
+*/
+type memoryFile struct {
+	*bytes.Reader
+	info    memoryFileInfo
+	entries []os.FileInfo
+	etag    string
+}
+
+func (f *memoryFile) Close() error {
+	return nil
+}
+
+func (f *memoryFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.dir {
+		return nil, os.ErrInvalid
+	}
+	if count <= 0 {
+		entries := f.entries
+		f.entries = nil
+		return entries, nil
+	}
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.entries) {
+		count = len(f.entries)
+	}
+	entries := f.entries[:count]
+	f.entries = f.entries[count:]
+	return entries, nil
+}
+
+func (f *memoryFile) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+/*
+Get precomputed content hash ETag (empty string, if it is not known)
+*/
+func (f *memoryFile) contentETag() string {
+	return f.etag
+}
+
+/*
+os.FileInfo implementation for memoryFileSystem (modification time is unknown, so zero time is returned) and other in-memory files
+*/
+type memoryFileInfo struct {
+	name    string
+	size    int64
+	dir     bool
+	modTime time.Time
+}
+
+func (fi memoryFileInfo) Name() string {
+	return fi.name
+}
+
+func (fi memoryFileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi memoryFileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi memoryFileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi memoryFileInfo) IsDir() bool {
+	return fi.dir
+}
+
+func (fi memoryFileInfo) Sys() interface{} {
+	return nil
+}
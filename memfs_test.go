@@ -0,0 +1,42 @@
+package webimizer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewMemoryFileServerHandler(t *testing.T) {
+	handler := NewMemoryFileServerHandler(map[string][]byte{
+		"/index.html":      []byte("<h1>home</h1>"),
+		"css/style.css":    []byte("body{}"),
+		"/error404.html":   []byte("<h1>not found</h1>"),
+		"/docs/guide.html": []byte("guide"),
+	})
+	tests := []struct {
+		target      string
+		status      int
+		body        string
+		contentType string
+	}{
+		{"/", http.StatusOK, "<h1>home</h1>", "text/html; charset=utf-8"},
+		{"/css/style.css", http.StatusOK, "body{}", "text/css; charset=utf-8"},
+		{"/docs/guide.html", http.StatusOK, "guide", "text/html; charset=utf-8"},
+		{"/missing.html", http.StatusNotFound, "<h1>not found</h1>", "text/html; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			rec := serveRequest(handler, http.MethodGet, tt.target, nil)
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("got %d %q, want %d %q", rec.Code, rec.Body.String(), tt.status, tt.body)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", ct, tt.contentType)
+			}
+		})
+	}
+
+	rec := serveRequest(NewMemoryFileServerHandler(map[string][]byte{"/a.txt": []byte("a")}), http.MethodGet, "/b.txt", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing file without error404.html got %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,304 @@
+package webimizer
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Check request Content-Type header (parameters like charset are ignored) and return 415 Unsupported Media Type status if it is not one of types. Only POST, PUT and PATCH requests are checked (requests of other methods, for example CORS preflight OPTIONS request, have no body)
+Example:
+
+	handler.WithRequireContentType("application/json")
+*/
+func (fn HttpHandler) WithRequireContentType(types ...string) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			fn(rw, r)
+			return
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err == nil {
+			for _, t := range types {
+				if strings.EqualFold(mediaType, t) {
+					fn(rw, r)
+					return
+				}
+			}
+		}
+		http.Error(rw, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+	})
+}
+
+/*
+Limit concurrent executions of handler to n. If n requests are already in-flight, 503 Service Unavailable status is returned immediately. If n <= 0, concurrency is not limited
+*/
+func (fn HttpHandler) WithConcurrencyLimit(n int) HttpHandler {
+	return fn.WithConcurrencyLimitTimeout(n, 0)
+}
+
+/*
+Limit concurrent executions of handler to n. If n requests are already in-flight, request waits in queue up to timeout and then 503 Service Unavailable status is returned. If n <= 0, concurrency is not limited
+*/
+func (fn HttpHandler) WithConcurrencyLimitTimeout(n int, timeout time.Duration) HttpHandler {
+	if n <= 0 {
+		return fn
+	}
+	sem := make(chan struct{}, n)
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		select {
+		case sem <- struct{}{}:
+		default:
+			if timeout <= 0 {
+				http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			}
+			timer := time.NewTimer(timeout)
+			defer timer.Stop()
+			select {
+			case sem <- struct{}{}:
+			case <-timer.C:
+				http.Error(rw, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+				return
+			case <-r.Context().Done():
+				return
+			}
+		}
+		defer func() { <-sem }()
+		fn(rw, r)
+	})
+}
+
+/*
+Set Content-Language response header (for example, "en" or "fr, en")
+*/
+func (fn HttpHandler) WithContentLanguage(lang string) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Language", lang)
+		fn(rw, r)
+	})
+}
+
+/*
+Set Timing-Allow-Origin response header, so pages of origins (for example, "https://example.com") can read detailed Resource Timing of response. Without origins (or with "*"), all origins are allowed. To allow origins of HttpHandlerStruct.AllowedOrigins, set HttpHandlerStruct.TimingAllowOrigin instead
+Example:
+
+	handler.WithTimingAllowOrigin("https://example.com", "https://www.example.com")
+*/
+func (fn HttpHandler) WithTimingAllowOrigin(origins ...string) HttpHandler {
+	value := "*"
+	if len(origins) > 0 && !containsString(origins, "*") {
+		value = strings.Join(origins, ", ")
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Timing-Allow-Origin", value)
+		fn(rw, r)
+	})
+}
+
+/*
+Return 400 Bad Request status for requests with missing or empty User-Agent header (often sent by bots and scanners). Requests to exemptPaths (for example, "/healthz") are always allowed, path ending with slash exempts all paths under it
+Example:
+
+	handler.WithRequireUserAgent("/healthz", "/.well-known/")
+*/
+func (fn HttpHandler) WithRequireUserAgent(exemptPaths ...string) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if strings.TrimSpace(r.Header.Get("User-Agent")) == "" && !exemptPath(r.URL.Path, exemptPaths) {
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		fn(rw, r)
+	})
+}
+
+func exemptPath(p string, exemptPaths []string) bool {
+	for _, exempt := range exemptPaths {
+		if p == exempt || (strings.HasSuffix(exempt, "/") && strings.HasPrefix(p, exempt)) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Check Expect request header before request body is sent: unknown expectations get 417 Expectation Failed status, requests with "Expect: 100-continue" and Content-Length bigger than maxBody bytes (if maxBody > 0) get 413 Request Entity Too Large status, so client does not send body. Otherwise "100 Continue" is sent (by net/http server), when handler starts reading request body. Body of such request without Content-Length is limited to maxBody bytes too. Requests without Expect header are not checked (use http.MaxBytesReader to limit their body)
+*/
+func (fn HttpHandler) WithExpectContinue(maxBody int64) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		expect := r.Header.Get("Expect")
+		if expect == "" {
+			fn(rw, r)
+			return
+		}
+		if !strings.EqualFold(strings.TrimSpace(expect), "100-continue") {
+			http.Error(rw, http.StatusText(http.StatusExpectationFailed), http.StatusExpectationFailed)
+			return
+		}
+		if maxBody > 0 {
+			if r.ContentLength > maxBody {
+				// body is not read, so connection can not be reused
+				rw.Header().Set("Connection", "close")
+				http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(rw, r.Body, maxBody)
+		}
+		fn(rw, r)
+	})
+}
+
+/*
+Set Keep-Alive hint header (for example, "timeout=5, max=100") with Connection: keep-alive header (Keep-Alive is hop-by-hop header, so it must be listed in Connection) for HTTP/1.x connections, so clients and proxies know how long idle connection is kept by server (max is not sent, if it is 0). Timeout is rounded down to seconds. If timeout is less than 1 second, Connection: close header is sent instead, so connection is closed after response. HTTP/2 forbids connection-specific headers, so nothing is set for HTTP/2 requests
+*/
+func (fn HttpHandler) WithKeepAlive(timeout time.Duration, max int) HttpHandler {
+	seconds := int(timeout / time.Second)
+	value := "timeout=" + strconv.Itoa(seconds)
+	if max > 0 {
+		value += ", max=" + strconv.Itoa(max)
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 1 {
+			h := rw.Header()
+			if seconds <= 0 {
+				// timeout=0 would advertise connection, which is closed at once
+				h.Set("Connection", "close")
+			} else {
+				h.Set("Connection", "keep-alive")
+				h.Set("Keep-Alive", value)
+			}
+		}
+		fn(rw, r)
+	})
+}
+
+/*
+Return 411 Length Required status for POST, PUT and PATCH requests without Content-Length header (for example, chunked uploads). Requests of other methods are not checked
+*/
+func (fn HttpHandler) WithRequireContentLength() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			// net/http sets ContentLength to -1, if body length is unknown
+			if r.ContentLength < 0 {
+				http.Error(rw, http.StatusText(http.StatusLengthRequired), http.StatusLengthRequired)
+				return
+			}
+		}
+		fn(rw, r)
+	})
+}
+
+/*
+Return 414 URI Too Long status for requests, whose request URI (path with query, as sent by client) is longer than n bytes. If n <= 0, length is not limited
+*/
+func (fn HttpHandler) WithMaxURLLength(n int) HttpHandler {
+	if n <= 0 {
+		return fn
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		uri := r.RequestURI
+		if uri == "" {
+			uri = r.URL.RequestURI()
+		}
+		if len(uri) > n {
+			http.Error(rw, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+			return
+		}
+		fn(rw, r)
+	})
+}
+
+/*
+Return 431 Request Header Fields Too Large status (with Connection: close header) for requests with more than maxCount header fields or more than maxBytes of header fields (each field is counted as "Name: value\r\n", Host header is included). 0 disables limit. Limit must be smaller than http.Server MaxHeaderBytes, because bigger headers are rejected by net/http before handler is called
+*/
+func (fn HttpHandler) WithHeaderLimits(maxCount int, maxBytes int) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		count, size := 0, 0
+		if r.Host != "" {
+			count++
+			size += len("Host: \r\n") + len(r.Host)
+		}
+		for name, values := range r.Header {
+			for _, value := range values {
+				count++
+				size += len(name) + len(value) + len(": \r\n")
+			}
+		}
+		if (maxCount > 0 && count > maxCount) || (maxBytes > 0 && size > maxBytes) {
+			rw.Header().Set("Connection", "close")
+			http.Error(rw, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+		fn(rw, r)
+	})
+}
+
+/*
+Return 400 Bad Request status for requests, whose decoded path contains ".." element, null byte or backslash (for example, /..%2f or /%00). It is defense-in-depth for file servers
+*/
+func (fn HttpHandler) WithPathValidation() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if !validPath(r.URL.Path) {
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		fn(rw, r)
+	})
+}
+
+func validPath(p string) bool {
+	if strings.ContainsAny(p, "\\\x00") {
+		return false
+	}
+	for _, name := range strings.Split(p, "/") {
+		if name == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+Disable range requests: Range request header is ignored (full response with 200 status is sent) and Accept-Ranges: none response header is set
+*/
+func (fn HttpHandler) WithoutRanges() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" || r.Header.Get("If-Range") != "" {
+			r = r.Clone(r.Context())
+			r.Header.Del("Range")
+			r.Header.Del("If-Range")
+		}
+		serveWithHeaderHook(rw, r, fn, func(h http.Header) {
+			h.Set("Accept-Ranges", "none")
+		})
+	})
+}
+
+/*
+Redirect GET and HEAD requests with non-canonical path (for example, /foo//bar or /foo/./bar) to cleaned path with 301 status. Query string is kept unchanged
+*/
+func (fn HttpHandler) WithCleanPath() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			fn(rw, r)
+			return
+		}
+		cleaned := cleanPath(r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") && cleaned != "/" {
+			cleaned += "/"
+		}
+		if cleaned == r.URL.Path {
+			fn(rw, r)
+			return
+		}
+		location := (&url.URL{Path: cleaned, RawQuery: r.URL.RawQuery}).String()
+		http.Redirect(rw, r, location, http.StatusMovedPermanently)
+	})
+}
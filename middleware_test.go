@@ -0,0 +1,414 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+/*
+Handler, which writes "ok" response
+*/
+func okHandler(rw http.ResponseWriter, r *http.Request) {
+	io.WriteString(rw, "ok")
+}
+
+func TestWithRequireContentType(t *testing.T) {
+	handler := HttpHandler(okHandler).WithRequireContentType("application/json")
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		want        int
+	}{
+		{"json", http.MethodPost, "application/json", http.StatusOK},
+		{"json with charset", http.MethodPut, "application/json; charset=utf-8", http.StatusOK},
+		{"json uppercase", http.MethodPost, "Application/JSON", http.StatusOK},
+		{"form", http.MethodPost, "application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"missing", http.MethodPatch, "", http.StatusUnsupportedMediaType},
+		{"malformed", http.MethodPost, "application/json; =", http.StatusUnsupportedMediaType},
+		{"get not checked", http.MethodGet, "text/plain", http.StatusOK},
+		{"delete not checked", http.MethodDelete, "", http.StatusOK},
+		{"cors preflight not checked", http.MethodOptions, "", http.StatusOK},
+		{"trace not checked", http.MethodTrace, "", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/api", nil)
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithConcurrencyLimit(t *testing.T) {
+	const n = 3
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		okHandler(rw, r)
+	}).WithConcurrencyLimit(n)
+
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			codes <- rec.Code
+		}()
+	}
+	for i := 0; i < n; i++ {
+		<-started
+	}
+	// all slots are in-flight, so extra requests are rejected immediately
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("extra request got %d, want 503", rec.Code)
+		}
+	}
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("in-flight request got %d, want 200", code)
+		}
+	}
+
+	// slots are released after requests are finished
+	go func() { <-started }()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("request after release got %d, want 200", rec.Code)
+	}
+}
+
+func TestWithConcurrencyLimitTimeout(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		okHandler(rw, r)
+	}).WithConcurrencyLimitTimeout(1, 20*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-started
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("queued request got %d, want 503 after timeout", rec.Code)
+	}
+
+	// queued request gets slot, when in-flight request is finished before timeout
+	codes := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		codes <- rec.Code
+	}()
+	close(release)
+	<-done
+	<-started
+	if code := <-codes; code != http.StatusOK {
+		t.Errorf("queued request got %d, want 200", code)
+	}
+}
+
+func TestWithConcurrencyLimitDisabled(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		rec := serveRequest(HttpHandler(okHandler).WithConcurrencyLimitTimeout(n, time.Second), http.MethodGet, "/", nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("limit %d got %d, want 200", n, rec.Code)
+		}
+	}
+}
+
+func TestWithContentLanguage(t *testing.T) {
+	rec := serveRequest(HttpHandler(okHandler).WithContentLanguage("fr, en"), http.MethodGet, "/", nil)
+	if got := rec.Header().Get("Content-Language"); got != "fr, en" {
+		t.Errorf("Content-Language = %q, want %q", got, "fr, en")
+	}
+}
+
+func TestWithPathValidation(t *testing.T) {
+	handler := HttpHandler(okHandler).WithPathValidation()
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{"/..%2f", http.StatusBadRequest},
+		{"/static/..%2F..%2Fetc/passwd", http.StatusBadRequest},
+		{"/%00", http.StatusBadRequest},
+		{"/file.txt%00.png", http.StatusBadRequest},
+		{"/dir%5c..%5cfile", http.StatusBadRequest},
+		{"/static/app.js", http.StatusOK},
+		{"/static/app..min.js", http.StatusOK},
+		{"/", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rec := serveRequest(handler, http.MethodGet, tt.target, nil); rec.Code != tt.want {
+			t.Errorf("%s got %d, want %d", tt.target, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestWithoutRanges(t *testing.T) {
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		http.ServeContent(rw, r, "stream.txt", time.Time{}, strings.NewReader("generated stream"))
+	}).WithoutRanges()
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Range": {"bytes=0-3"}, "If-Range": {`"v1"`}})
+	if rec.Code != http.StatusOK || rec.Body.String() != "generated stream" || rec.Header().Get("Accept-Ranges") != "none" {
+		t.Errorf("got %d %q Accept-Ranges %q, want 200 full response with Accept-Ranges: none", rec.Code, rec.Body.String(), rec.Header().Get("Accept-Ranges"))
+	}
+}
+
+func TestWithCleanPath(t *testing.T) {
+	handler := HttpHandler(okHandler).WithCleanPath()
+	tests := []struct {
+		method   string
+		target   string
+		location string
+	}{
+		{http.MethodGet, "/foo//bar", "/foo/bar"},
+		{http.MethodGet, "/foo/./bar", "/foo/bar"},
+		{http.MethodHead, "/foo/baz/../bar?q=a%2Fb&x=1", "/foo/bar?q=a%2Fb&x=1"},
+		{http.MethodGet, "//foo//", "/foo/"},
+		{http.MethodGet, "/foo/bar", ""},
+		{http.MethodGet, "/foo/", ""},
+		{http.MethodPost, "/foo//bar", ""},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(handler, tt.method, tt.target, nil)
+		if tt.location == "" {
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s %s got %d, want 200 without redirect", tt.method, tt.target, rec.Code)
+			}
+			continue
+		}
+		if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s got %d %q, want 301 to %q", tt.method, tt.target, rec.Code, rec.Header().Get("Location"), tt.location)
+		}
+	}
+}
+
+func TestWithTimingAllowOrigin(t *testing.T) {
+	files := NewMemoryFileServerHandler(map[string][]byte{"/app.css": []byte("body{}")})
+	tests := []struct {
+		origins []string
+		want    string
+	}{
+		{nil, "*"},
+		{[]string{"https://example.com", "*"}, "*"},
+		{[]string{"https://example.com", "https://www.example.com"}, "https://example.com, https://www.example.com"},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(files.WithTimingAllowOrigin(tt.origins...), http.MethodGet, "/app.css", nil)
+		if rec.Code != http.StatusOK || rec.Header().Get("Timing-Allow-Origin") != tt.want {
+			t.Errorf("origins %q got %d Timing-Allow-Origin %q, want %q", tt.origins, rec.Code, rec.Header().Get("Timing-Allow-Origin"), tt.want)
+		}
+	}
+}
+
+func TestWithRequireUserAgent(t *testing.T) {
+	handler := HttpHandler(okHandler).WithRequireUserAgent("/healthz", "/.well-known/")
+	tests := []struct {
+		name      string
+		path      string
+		userAgent []string
+		want      int
+	}{
+		{"missing", "/", nil, http.StatusBadRequest},
+		{"empty", "/", []string{" "}, http.StatusBadRequest},
+		{"present", "/", []string{"Mozilla/5.0"}, http.StatusOK},
+		{"exempt path", "/healthz", nil, http.StatusOK},
+		{"exempt prefix", "/.well-known/security.txt", nil, http.StatusOK},
+		{"not exempt similar path", "/healthz/details", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := serveRequest(handler, http.MethodGet, tt.path, http.Header{"User-Agent": tt.userAgent})
+		if rec.Code != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestWithExpectContinue(t *testing.T) {
+	called := false
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		called = true
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(rw, err.Error(), http.StatusRequestEntityTooLarge)
+		}
+	}).WithExpectContinue(1024)
+	tests := []struct {
+		expect        string
+		contentLength int64
+		want          int
+		wantCalled    bool
+	}{
+		{"100-continue", 4096, http.StatusRequestEntityTooLarge, false},
+		{"100-continue", 512, http.StatusOK, true},
+		{"something-else", 512, http.StatusExpectationFailed, false},
+		{"", 4096, http.StatusOK, true},
+	}
+	for _, test := range tests {
+		called = false
+		r := httptest.NewRequest(http.MethodPut, "/upload", strings.NewReader(strings.Repeat("a", int(test.contentLength))))
+		if test.expect != "" {
+			r.Header.Set("Expect", test.expect)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != test.want || called != test.wantCalled {
+			t.Errorf("Expect %q with Content-Length %d: status %d, handler called %v, want %d and %v", test.expect, test.contentLength, rec.Code, called, test.want, test.wantCalled)
+		}
+	}
+	r := httptest.NewRequest(http.MethodPut, "/upload", strings.NewReader(strings.Repeat("a", 4096)))
+	r.Header.Set("Expect", "100-continue")
+	r.ContentLength = -1
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, r)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("body without Content-Length: status %d, want 413", rec.Code)
+	}
+}
+
+func TestWithMaxURLLength(t *testing.T) {
+	called := false
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		called = true
+		okHandler(rw, r)
+	}).WithMaxURLLength(64)
+	prefix := "/search?q="
+	tests := []struct {
+		target string
+		want   int
+	}{
+		{prefix + strings.Repeat("a", 64-len(prefix)), http.StatusOK},
+		{prefix + strings.Repeat("a", 65-len(prefix)), http.StatusRequestURITooLong},
+	}
+	for _, test := range tests {
+		called = false
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.target, nil))
+		if rec.Code != test.want || called != (test.want == http.StatusOK) {
+			t.Errorf("URL of %d bytes: status %d, handler called %v, want %d", len(test.target), rec.Code, called, test.want)
+		}
+	}
+}
+
+func TestWithMaxURLLengthDisabled(t *testing.T) {
+	for _, n := range []int{0, -1} {
+		rec := serveRequest(HttpHandler(okHandler).WithMaxURLLength(n), http.MethodGet, "/search?q="+strings.Repeat("a", 100), nil)
+		if rec.Code != http.StatusOK {
+			t.Errorf("limit %d got %d, want 200", n, rec.Code)
+		}
+	}
+}
+
+func TestWithKeepAlive(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    HttpHandler
+		protoMajor int
+		keepAlive  string
+		connection string
+	}{
+		{"HTTP/1.1", HttpHandler(okHandler).WithKeepAlive(5*time.Second, 100), 1, "timeout=5, max=100", "keep-alive"},
+		{"HTTP/1.1 without max", HttpHandler(okHandler).WithKeepAlive(30*time.Second, 0), 1, "timeout=30", "keep-alive"},
+		{"HTTP/1.1 close", HttpHandler(okHandler).WithKeepAlive(0, 0), 1, "", "close"},
+		{"HTTP/1.1 sub-second", HttpHandler(okHandler).WithKeepAlive(500*time.Millisecond, 100), 1, "", "close"},
+		{"HTTP/1.1 rounded down", HttpHandler(okHandler).WithKeepAlive(1500*time.Millisecond, 0), 1, "timeout=1", "keep-alive"},
+		{"HTTP/2", HttpHandler(okHandler).WithKeepAlive(5*time.Second, 100), 2, "", ""},
+		{"HTTP/2 close", HttpHandler(okHandler).WithKeepAlive(0, 0), 2, "", ""},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.ProtoMajor, r.ProtoMinor = test.protoMajor, 1
+		if test.protoMajor == 2 {
+			r.Proto, r.ProtoMinor = "HTTP/2.0", 0
+		}
+		rec := httptest.NewRecorder()
+		test.handler.ServeHTTP(rec, r)
+		if got := rec.Header().Get("Keep-Alive"); got != test.keepAlive {
+			t.Errorf("%s: Keep-Alive = %q, want %q", test.name, got, test.keepAlive)
+		}
+		if got := rec.Header().Get("Connection"); got != test.connection {
+			t.Errorf("%s: Connection = %q, want %q", test.name, got, test.connection)
+		}
+	}
+}
+
+func TestWithHeaderLimits(t *testing.T) {
+	// Host: example.com is counted as 19 bytes
+	tests := []struct {
+		name     string
+		maxCount int
+		maxBytes int
+		header   http.Header
+		want     int
+	}{
+		{"count under", 3, 0, http.Header{"X-A": {"1"}, "X-B": {"2"}}, http.StatusOK},
+		{"count over", 3, 0, http.Header{"X-A": {"1"}, "X-B": {"2", "3"}}, http.StatusRequestHeaderFieldsTooLarge},
+		{"bytes under", 0, 100, http.Header{"X-Data": {strings.Repeat("a", 71)}}, http.StatusOK},
+		{"bytes over", 0, 100, http.Header{"X-Data": {strings.Repeat("a", 72)}}, http.StatusRequestHeaderFieldsTooLarge},
+		{"no limits", 0, 0, http.Header{"X-Data": {strings.Repeat("a", 10000)}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		rec := serveRequest(HttpHandler(okHandler).WithHeaderLimits(test.maxCount, test.maxBytes), http.MethodGet, "/", test.header)
+		if rec.Code != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, rec.Code, test.want)
+		}
+		if test.want != http.StatusOK && rec.Header().Get("Connection") != "close" {
+			t.Errorf("%s: Connection: close is not set", test.name)
+		}
+	}
+}
+
+func TestWithRequireContentLength(t *testing.T) {
+	handler := HttpHandler(okHandler).WithRequireContentLength()
+	tests := []struct {
+		method        string
+		contentLength int64
+		want          int
+	}{
+		{http.MethodPost, 5, http.StatusOK},
+		{http.MethodPost, 0, http.StatusOK},
+		{http.MethodPost, -1, http.StatusLengthRequired},
+		{http.MethodPut, -1, http.StatusLengthRequired},
+		{http.MethodPatch, -1, http.StatusLengthRequired},
+		{http.MethodGet, -1, http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, "/upload", strings.NewReader("hello"))
+		r.ContentLength = test.contentLength
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, r)
+		if rec.Code != test.want {
+			t.Errorf("%s with Content-Length %d got %d, want %d", test.method, test.contentLength, rec.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package webimizer
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+/*
+Error returned by ParseMultipartStream, when non-file form values exceed maxMemory bytes
+*/
+var ErrMultipartValuesTooLarge = errors.New("webimizer: multipart form values are too large")
+
+/*
+Read multipart/form-data request body part by part without buffering files: onFile is called for each file part (part must be read in callback, for example copied to disk). Other form values (up to maxMemory bytes in total) are added to r.PostForm and r.Form. If onFile returns error, parsing stops and the error is returned
+Example:
+
+	err := app.ParseMultipartStream(r, 1<<20, func(part *multipart.Part) error {
+		f, err := os.Create(filepath.Join(uploadDir, filepath.Base(part.FileName())))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(f, part)
+		return err
+	})
+*/
+func ParseMultipartStream(r *http.Request, maxMemory int64, onFile func(part *multipart.Part) error) error {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
+	}
+	if r.Form == nil {
+		r.Form = make(url.Values)
+		for k, v := range r.URL.Query() {
+			r.Form[k] = v
+		}
+	}
+	remaining := maxMemory
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if part.FileName() != "" {
+			err = onFile(part)
+			part.Close()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		name := part.FormName()
+		if name == "" {
+			part.Close()
+			continue
+		}
+		value, err := io.ReadAll(io.LimitReader(part, remaining+1))
+		part.Close()
+		if err != nil {
+			return err
+		}
+		remaining -= int64(len(value))
+		if remaining < 0 {
+			return ErrMultipartValuesTooLarge
+		}
+		r.PostForm.Add(name, string(value))
+		r.Form.Add(name, string(value))
+	}
+}
@@ -0,0 +1,80 @@
+package webimizer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/*
+Create multipart/form-data POST request with form values and files (file name and content pairs)
+*/
+func newMultipartRequest(t *testing.T, values map[string]string, files [][2]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range values {
+		mw.WriteField(name, value)
+	}
+	for _, file := range files {
+		fw, err := mw.CreateFormFile("upload", file[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(fw, file[1])
+	}
+	if err := mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/upload?album=1", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	return r
+}
+
+func TestParseMultipartStream(t *testing.T) {
+	r := newMultipartRequest(t, map[string]string{"title": "holiday"}, [][2]string{{"a.txt", "first file"}, {"b.txt", strings.Repeat("b", 100000)}})
+	received := map[string]string{}
+	err := ParseMultipartStream(r, 1024, func(part *multipart.Part) error {
+		content, err := io.ReadAll(part)
+		received[part.FileName()] = string(content)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 2 || received["a.txt"] != "first file" || received["b.txt"] != strings.Repeat("b", 100000) {
+		t.Errorf("callback received %d files, want a.txt and b.txt with content", len(received))
+	}
+	if r.PostFormValue("title") != "holiday" || r.FormValue("album") != "1" {
+		t.Errorf("form values title %q album %q", r.PostFormValue("title"), r.FormValue("album"))
+	}
+}
+
+func TestParseMultipartStreamErrors(t *testing.T) {
+	errStop := errors.New("stop")
+	calls := 0
+	r := newMultipartRequest(t, nil, [][2]string{{"a.txt", "a"}, {"b.txt", "b"}})
+	err := ParseMultipartStream(r, 1024, func(part *multipart.Part) error {
+		calls++
+		return errStop
+	})
+	if err != errStop || calls != 1 {
+		t.Errorf("got %v after %d calls, want callback error after first file", err, calls)
+	}
+
+	r = newMultipartRequest(t, map[string]string{"comment": strings.Repeat("c", 100)}, nil)
+	if err := ParseMultipartStream(r, 10, func(*multipart.Part) error { return nil }); err != ErrMultipartValuesTooLarge {
+		t.Errorf("got %v, want ErrMultipartValuesTooLarge", err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("a=1"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := ParseMultipartStream(r, 10, func(*multipart.Part) error { return nil }); err == nil {
+		t.Error("non-multipart request is accepted")
+	}
+}
@@ -0,0 +1,50 @@
+package webimizer
+
+import (
+	"bytes"
+	"sync"
+)
+
+/*
+Define maximum capacity of byte buffer, which is returned to buffer pool (used by gzip min-length buffering, WithBuffering and WithClientDeadline). Bigger buffers are released to garbage collector. 0 disables buffer pooling
+*/
+var BufferPoolMaxSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+/*
+Get empty byte buffer from pool
+*/
+func getBuffer() *bytes.Buffer {
+	if BufferPoolMaxSize <= 0 {
+		return new(bytes.Buffer)
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+/*
+Return byte buffer to pool. Buffer must not be used after it
+*/
+func putBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > BufferPoolMaxSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// Size of copy buffer used by gzipResponseWriter.ReadFrom
+const copyBufferSize = 32 << 10
+
+var copyBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
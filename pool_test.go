@@ -0,0 +1,77 @@
+package webimizer
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+/*
+http.ResponseWriter, which discards response (benchmarks measure only allocations of handler chain)
+*/
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) {}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func TestBufferPool(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("data")
+	putBuffer(buf)
+	if buf := getBuffer(); buf.Len() != 0 {
+		t.Errorf("buffer from pool is not empty: %q", buf.String())
+	}
+
+	setForTest(t, &BufferPoolMaxSize, 16)
+	big := bytes.NewBuffer(make([]byte, 0, 64))
+	putBuffer(big)
+	if buf := getBuffer(); buf == big {
+		t.Error("buffer bigger than BufferPoolMaxSize is returned to pool")
+	}
+}
+
+func BenchmarkGzipMinLengthBuffered(b *testing.B) {
+	setForTest(b, &GzipMinLength, 1024)
+	n := 0
+	body := bytes.Repeat([]byte("webimizer "), 200)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		// small writes without Content-Length are buffered until GzipMinLength is reached
+		rw.Write(body[:n])
+	})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	for _, bench := range []struct {
+		name     string
+		poolSize int
+	}{
+		{"pool", 64 << 10},
+		{"nopool", 0},
+	} {
+		for _, size := range []struct {
+			name string
+			n    int
+		}{
+			{"small", 512},
+			{"large", len(body)},
+		} {
+			b.Run(bench.name+"/"+size.name, func(b *testing.B) {
+				setForTest(b, &BufferPoolMaxSize, bench.poolSize)
+				n = size.n
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					handler.ServeHTTP(&discardResponseWriter{header: http.Header{}}, r)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package webimizer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Body compressed once (with GzipCompressionLevel) and ETags computed from it
+*/
+type staticContent struct {
+	body       []byte
+	compressed []byte
+	etag       string
+	gzipETag   string
+}
+
+func newStaticContent(body []byte) (*staticContent, error) {
+	var compressed bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&compressed, GzipCompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	gz.Write(body)
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	etag := contentHashETag(body)
+	// representations must have different strong ETags
+	gzipETag := strings.TrimSuffix(etag, `"`) + `-gzip"`
+	return &staticContent{body: body, compressed: compressed.Bytes(), etag: etag, gzipETag: gzipETag}, nil
+}
+
+/*
+Write gzip compressed body to clients accepting gzip and uncompressed body to others. ETag header is set only for safe methods (GET and HEAD), which can be conditional (see conditionalMethod). Their responses with 200 OK status are served by http.ServeContent, so conditional and range requests are supported
+*/
+func (c *staticContent) serve(rw http.ResponseWriter, r *http.Request, status int) {
+	h := rw.Header()
+	addVary(h, "Accept-Encoding")
+	content, etag := c.body, c.etag
+	if acceptEncodingQuality(r.Header.Get("Accept-Encoding"), "gzip") > 0 {
+		etag = c.gzipETag
+		h.Set("Content-Encoding", "gzip")
+		content = c.compressed
+	}
+	disableGzip(rw)
+	if conditionalMethod(r.Method) {
+		h.Set("ETag", etag)
+		if status == http.StatusOK {
+			http.ServeContent(rw, r, "", time.Time{}, bytes.NewReader(content))
+			return
+		}
+	}
+	h.Set("Content-Length", strconv.Itoa(len(content)))
+	rw.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		rw.Write(content)
+	}
+}
+
+type precomputedResponse struct {
+	status      int
+	contentType string
+	content     *staticContent
+}
+
+/*
+Thread-safe set of precomputed responses keyed by exact request path (for mostly static API). Use NewPrecomputedResponseHandler to create it, Register to add responses and Handler to serve them
+*/
+type PrecomputedResponseHandler struct {
+	mu        sync.RWMutex
+	responses map[string]*precomputedResponse
+}
+
+/*
+Create empty PrecomputedResponseHandler
+*/
+func NewPrecomputedResponseHandler() *PrecomputedResponseHandler {
+	return &PrecomputedResponseHandler{responses: map[string]*precomputedResponse{}}
+}
+
+/*
+Register response for request path (previous response of the same path is replaced). Body is compressed once, so error is returned only if compression fails
+Example:
+
+	responses := app.NewPrecomputedResponseHandler()
+	responses.Register("/status", http.StatusOK, "application/json", []byte(`{"status":"ok"}`))
+	http.Handle("/", responses.Handler())
+*/
+func (p *PrecomputedResponseHandler) Register(path string, status int, contentType string, body []byte) error {
+	content, err := newStaticContent(body)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.responses[path] = &precomputedResponse{status: status, contentType: contentType, content: content}
+	return nil
+}
+
+/*
+Create HttpHandler, which serves registered responses (gzip compressed, if client accepts it, with ETag header). Not registered paths get 404 Not Found status
+*/
+func (p *PrecomputedResponseHandler) Handler() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		p.mu.RLock()
+		response, ok := p.responses[r.URL.Path]
+		p.mu.RUnlock()
+		if !ok {
+			http.NotFound(rw, r)
+			return
+		}
+		if response.contentType != "" {
+			rw.Header().Set("Content-Type", response.contentType)
+		}
+		response.content.serve(rw, r, response.status)
+	})
+}
@@ -0,0 +1,47 @@
+package webimizer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPrecomputedResponseHandler(t *testing.T) {
+	responses := NewPrecomputedResponseHandler()
+	if err := responses.Register("/status", http.StatusOK, "application/json", []byte(`{"status":"ok"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := responses.Register("/gone", http.StatusGone, "application/json", []byte(`{"error":"gone"}`)); err != nil {
+		t.Fatal(err)
+	}
+	handler := responses.Handler()
+
+	rec := serveRequest(handler, http.MethodGet, "/status", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"status":"ok"}` || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("/status got %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	etag := rec.Header().Get("ETag")
+	rec = serveRequest(handler, http.MethodGet, "/status", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" || gunzip(t, rec.Body.Bytes()) != `{"status":"ok"}` {
+		t.Errorf("compressed /status got Content-Encoding %q", rec.Header().Get("Content-Encoding"))
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("compressed and uncompressed responses have the same ETag")
+	}
+	if rec := serveRequest(handler, http.MethodGet, "/status", http.Header{"If-None-Match": {etag}}); rec.Code != http.StatusNotModified {
+		t.Errorf("conditional /status got %d, want 304", rec.Code)
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/gone", nil)
+	if rec.Code != http.StatusGone || rec.Body.String() != `{"error":"gone"}` {
+		t.Errorf("/gone got %d %q, want registered status and body", rec.Code, rec.Body.String())
+	}
+
+	if rec := serveRequest(handler, http.MethodGet, "/missing", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("not registered path got %d, want 404", rec.Code)
+	}
+
+	responses.Register("/status", http.StatusOK, "application/json", []byte(`{"status":"degraded"}`))
+	if rec := serveRequest(handler, http.MethodGet, "/status", nil); rec.Body.String() != `{"status":"degraded"}` {
+		t.Errorf("replaced /status got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,33 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+Structured error response body (RFC 7807)
+*/
+type Problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+/*
+Write structured error response (RFC 7807) with Content-Type: application/problem+json. Problem type is "about:blank", so title should be http.StatusText(status) or similar short summary
+Example:
+
+	app.WriteProblem(rw, http.StatusBadRequest, "Bad Request", "field 'name' is required")
+*/
+func WriteProblem(w http.ResponseWriter, status int, title, detail string) {
+	writeProblem(w, Problem{Type: "about:blank", Title: title, Status: status, Detail: detail})
+}
+
+func writeProblem(w http.ResponseWriter, problem Problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(problem.Status)
+	json.NewEncoder(w).Encode(problem)
+}
@@ -0,0 +1,190 @@
+package webimizer
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+/*
+Define trusted reverse proxies (IP addresses or CIDR ranges). Forwarded, X-Forwarded-For and X-Forwarded-Proto request headers are used only if request came from trusted proxy
+Example:
+
+	app.TrustedProxies = []string{"127.0.0.1", "10.0.0.0/8"}
+*/
+var TrustedProxies []string
+
+/*
+One element of Forwarded request header (RFC 7239)
+*/
+type forwardedElement struct {
+	For   string
+	Proto string
+	Host  string
+}
+
+/*
+Parse Forwarded request header (RFC 7239), for example:
+
+	Forwarded: for=192.0.2.60;proto=http, for="[2001:db8:cafe::17]:4711"
+*/
+func parseForwarded(header string) []forwardedElement {
+	var elements []forwardedElement
+	for _, part := range splitQuoted(header, ',') {
+		var element forwardedElement
+		for _, pair := range splitQuoted(part, ';') {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+			switch strings.ToLower(strings.TrimSpace(kv[0])) {
+			case "for":
+				element.For = value
+			case "proto":
+				element.Proto = strings.ToLower(value)
+			case "host":
+				element.Host = value
+			}
+		}
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+/*
+Split s by sep, but ignore separators in quoted strings
+*/
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			quoted = !quoted
+		case sep:
+			if !quoted {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+/*
+Get IP address from Forwarded "for" value or X-Forwarded-For list item (port and IPv6 brackets are removed)
+*/
+func forwardedIP(value string) string {
+	value = strings.TrimSpace(value)
+	if host, _, err := net.SplitHostPort(value); err == nil {
+		value = host
+	}
+	return strings.Trim(value, "[]")
+}
+
+/*
+Check if ip is in TrustedProxies list
+*/
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, proxy := range TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			if _, network, err := net.ParseCIDR(proxy); err == nil && network.Contains(parsed) {
+				return true
+			}
+		} else if proxyIP := net.ParseIP(proxy); proxyIP != nil && proxyIP.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+Get all lines of request header joined with commas (proxy appends its own line, so client can not hide it by sending the same header)
+*/
+func joinedHeader(r *http.Request, name string) string {
+	return strings.Join(r.Header.Values(name), ",")
+}
+
+func remoteIP(r *http.Request) string {
+	return forwardedIP(r.RemoteAddr)
+}
+
+/*
+Get client IP address. If request came from trusted proxy, Forwarded (RFC 7239) or X-Forwarded-For request header is used: the rightmost address, which is not trusted proxy, is returned
+*/
+func ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+	var chain []string
+	if header := joinedHeader(r, "Forwarded"); header != "" {
+		for _, element := range parseForwarded(header) {
+			chain = append(chain, forwardedIP(element.For))
+		}
+	} else if header := joinedHeader(r, "X-Forwarded-For"); header != "" {
+		for _, item := range strings.Split(header, ",") {
+			chain = append(chain, forwardedIP(item))
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if net.ParseIP(chain[i]) == nil {
+			// unknown or obfuscated identifier, can not go further
+			break
+		}
+		ip = chain[i]
+		if !isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+/*
+Get request scheme ("http" or "https"). If request came from trusted proxy, Forwarded (RFC 7239) or X-Forwarded-Proto request header is used
+*/
+func RequestScheme(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if !isTrustedProxy(remoteIP(r)) {
+		return scheme
+	}
+	if header := joinedHeader(r, "Forwarded"); header != "" {
+		elements := parseForwarded(header)
+		for i := len(elements) - 1; i >= 0; i-- {
+			if elements[i].Proto != "" {
+				return elements[i].Proto
+			}
+		}
+	} else if header := joinedHeader(r, "X-Forwarded-Proto"); header != "" {
+		// the rightmost value is set by the nearest proxy
+		protos := strings.Split(header, ",")
+		return strings.ToLower(strings.TrimSpace(protos[len(protos)-1]))
+	}
+	return scheme
+}
+
+/*
+Redirect request to https:// url, if RequestScheme is not https. GET and HEAD requests are redirected with 301 status, other methods with 308 status
+*/
+func (fn HttpHandler) WithRedirectToHTTPS() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if RequestScheme(r) == "https" {
+			fn(rw, r)
+			return
+		}
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(rw, r, "https://"+r.Host+r.URL.RequestURI(), code)
+	})
+}
@@ -0,0 +1,96 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func withTrustedProxies(t *testing.T, proxies ...string) {
+	t.Helper()
+	old := TrustedProxies
+	TrustedProxies = proxies
+	t.Cleanup(func() { TrustedProxies = old })
+}
+
+func newForwardedRequest(remoteAddr string, header http.Header) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	return r
+}
+
+func TestClientIP(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.0/8")
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"direct", "192.0.2.1:1234", nil, "192.0.2.1"},
+		{"untrusted peer ignores headers", "192.0.2.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6"}}, "192.0.2.1"},
+		{"x-forwarded-for", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"203.0.113.9"}}, "203.0.113.9"},
+		{"x-forwarded-for chain", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6, 203.0.113.9, 10.0.0.2"}}, "203.0.113.9"},
+		{"x-forwarded-for spoofed line", "10.0.0.1:1234", http.Header{"X-Forwarded-For": {"6.6.6.6", "203.0.113.9"}}, "203.0.113.9"},
+		{"forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.60;proto=http;by=203.0.113.43"}}, "192.0.2.60"},
+		{"forwarded ipv6 with port", "10.0.0.1:1234", http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711"`}}, "2001:db8:cafe::17"},
+		{"forwarded list", "10.0.0.1:1234", http.Header{"Forwarded": {"for=6.6.6.6, for=198.51.100.17"}}, "198.51.100.17"},
+		{"forwarded spoofed line", "10.0.0.1:1234", http.Header{"Forwarded": {"for=6.6.6.6", "for=198.51.100.17"}}, "198.51.100.17"},
+		{"forwarded has precedence", "10.0.0.1:1234", http.Header{"Forwarded": {"for=198.51.100.17"}, "X-Forwarded-For": {"6.6.6.6"}}, "198.51.100.17"},
+		{"obfuscated identifier", "10.0.0.1:1234", http.Header{"Forwarded": {"for=6.6.6.6, for=_hidden"}}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClientIP(newForwardedRequest(tt.remoteAddr, tt.header)); got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestScheme(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.1")
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{
+		{"direct", "192.0.2.1:1234", nil, "http"},
+		{"untrusted peer ignores headers", "192.0.2.1:1234", http.Header{"Forwarded": {"proto=https"}}, "http"},
+		{"forwarded", "10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.60;proto=https"}}, "https"},
+		{"forwarded uppercase", "10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.60;PROTO=HTTPS"}}, "https"},
+		{"forwarded spoofed line", "10.0.0.1:1234", http.Header{"Forwarded": {"proto=https", "for=192.0.2.60;proto=http"}}, "http"},
+		{"x-forwarded-proto", "10.0.0.1:1234", http.Header{"X-Forwarded-Proto": {"https"}}, "https"},
+		{"x-forwarded-proto spoofed line", "10.0.0.1:1234", http.Header{"X-Forwarded-Proto": {"https", "http"}}, "http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RequestScheme(newForwardedRequest(tt.remoteAddr, tt.header)); got != tt.want {
+				t.Errorf("RequestScheme() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithRedirectToHTTPSForwarded(t *testing.T) {
+	withTrustedProxies(t, "10.0.0.1")
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {}).WithRedirectToHTTPS()
+
+	rec := httptest.NewRecorder()
+	handler(rec, newForwardedRequest("10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.60;proto=http"}}))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://example.com/" {
+		t.Errorf("got %d %q, want redirect to https://example.com/", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, newForwardedRequest("10.0.0.1:1234", http.Header{"Forwarded": {"for=192.0.2.60;proto=https"}}))
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d, want 200 for https request", rec.Code)
+	}
+}
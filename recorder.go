@@ -0,0 +1,86 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+/*
+Information about one recorded request
+*/
+type RequestRecord struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Path     string        `json:"path"`
+	Status   int           `json:"status"`
+	Duration time.Duration `json:"duration"`
+}
+
+/*
+Thread-safe ring buffer of last requests (for dev-mode debugging). Use NewRequestRecorder to create it, HttpHandler.WithRequestRecorder to record requests and DebugHandler to show them
+*/
+type RequestRecorder struct {
+	mu      sync.Mutex
+	records []RequestRecord
+	next    int
+	full    bool
+}
+
+/*
+Create RequestRecorder, which keeps last size requests
+*/
+func NewRequestRecorder(size int) *RequestRecorder {
+	if size < 1 {
+		size = 1
+	}
+	return &RequestRecorder{records: make([]RequestRecord, size)}
+}
+
+func (recorder *RequestRecorder) add(record RequestRecord) {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.records[recorder.next] = record
+	recorder.next = (recorder.next + 1) % len(recorder.records)
+	if recorder.next == 0 {
+		recorder.full = true
+	}
+}
+
+/*
+Get recorded requests (oldest first)
+*/
+func (recorder *RequestRecorder) Records() []RequestRecord {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if !recorder.full {
+		return append([]RequestRecord{}, recorder.records[:recorder.next]...)
+	}
+	return append(append([]RequestRecord{}, recorder.records[recorder.next:]...), recorder.records[:recorder.next]...)
+}
+
+/*
+Create HttpHandler, which renders recorded requests as JSON
+*/
+func (recorder *RequestRecorder) DebugHandler() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(rw).Encode(recorder.Records())
+	})
+}
+
+/*
+Record method, path, status and duration of each request to recorder
+*/
+func (fn HttpHandler) WithRequestRecorder(recorder *RequestRecorder) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &countingResponseWriter{ResponseWriter: rw}
+		defer func() {
+			recorder.add(RequestRecord{Time: start, Method: r.Method, Path: r.URL.Path, Status: sw.Status(), Duration: time.Since(start)})
+		}()
+		fn(sw, r)
+	})
+}
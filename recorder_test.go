@@ -0,0 +1,66 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestRequestRecorder(t *testing.T) {
+	recorder := NewRequestRecorder(3)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(rw, r)
+			return
+		}
+		okHandler(rw, r)
+	}).WithRequestRecorder(recorder)
+	for _, target := range []string{"/first", "/second", "/missing", "/last"} {
+		serveRequest(handler, http.MethodGet, target, nil)
+	}
+	serveRequest(handler, http.MethodPost, "/last", nil)
+
+	rec := serveRequest(recorder.DebugHandler(), http.MethodGet, "/debug/requests", nil)
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var records []RequestRecord
+	if err := json.Unmarshal(rec.Body.Bytes(), &records); err != nil {
+		t.Fatal(err)
+	}
+	want := []RequestRecord{
+		{Method: http.MethodGet, Path: "/missing", Status: http.StatusNotFound},
+		{Method: http.MethodGet, Path: "/last", Status: http.StatusOK},
+		{Method: http.MethodPost, Path: "/last", Status: http.StatusOK},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want last %d: %+v", len(records), len(want), records)
+	}
+	for i, record := range records {
+		if record.Method != want[i].Method || record.Path != want[i].Path || record.Status != want[i].Status {
+			t.Errorf("record %d = %s %s %d, want %s %s %d", i, record.Method, record.Path, record.Status, want[i].Method, want[i].Path, want[i].Status)
+		}
+		if record.Time.IsZero() || record.Duration < 0 {
+			t.Errorf("record %d has invalid time %v or duration %v", i, record.Time, record.Duration)
+		}
+	}
+}
+
+func TestRequestRecorderConcurrent(t *testing.T) {
+	recorder := NewRequestRecorder(10)
+	handler := HttpHandler(okHandler).WithRequestRecorder(recorder)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveRequest(handler, http.MethodGet, "/", nil)
+			recorder.Records()
+		}()
+	}
+	wg.Wait()
+	if n := len(recorder.Records()); n != 10 {
+		t.Errorf("got %d records, want 10", n)
+	}
+}
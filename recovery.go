@@ -0,0 +1,40 @@
+package webimizer
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+/*
+Recover from handler panic and send 500 Internal Server Error status with structured error body (RFC 7807), which contains opaque incident ID. Panic value and stack trace are logged with the same incident ID, so it can be found by ID reported by client. If handler already started writing response, only log record is written. http.ErrAbortHandler panic is not recovered
+*/
+func (fn HttpHandler) WithRecoveryJSON() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		sw := &countingResponseWriter{ResponseWriter: rw}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			id := newRandomID()
+			log.Printf("webimizer: incident %s: panic serving %s %s: %v\n%s", id, r.Method, r.URL.Path, p, debug.Stack())
+			if sw.Written() {
+				return
+			}
+			// headers describing body written by handler are not valid for error body
+			sw.Header().Del("Content-Length")
+			sw.Header().Del("Content-Encoding")
+			writeProblem(sw, Problem{
+				Type:   "about:blank",
+				Title:  http.StatusText(http.StatusInternalServerError),
+				Status: http.StatusInternalServerError,
+				Detail: "incident ID: " + id,
+			})
+		}()
+		fn(sw, r)
+	})
+}
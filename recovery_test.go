@@ -0,0 +1,84 @@
+package webimizer
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+/*
+Capture output of standard logger for test duration
+*/
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	writer, flags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(writer)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestWithRecoveryJSON(t *testing.T) {
+	logs := captureLog(t)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Length", "100")
+		panic("database is down")
+	}).WithRecoveryJSON()
+	rec := serveRequest(handler, http.MethodGet, "/api/items", nil)
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("got %d %q, want 500 application/problem+json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var problem Problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusInternalServerError || problem.Title != "Internal Server Error" || problem.Type != "about:blank" {
+		t.Errorf("problem = %+v", problem)
+	}
+	id := strings.TrimPrefix(problem.Detail, "incident ID: ")
+	if id == "" || id == problem.Detail {
+		t.Fatalf("problem detail %q has no incident ID", problem.Detail)
+	}
+	if strings.Contains(rec.Body.String(), "database is down") {
+		t.Error("panic value is sent to client")
+	}
+	logged := logs.String()
+	if !strings.Contains(logged, "incident "+id) || !strings.Contains(logged, "database is down") || !strings.Contains(logged, "GET /api/items") {
+		t.Errorf("log record %q does not contain incident ID %s, request and panic value", logged, id)
+	}
+}
+
+func TestWithRecoveryJSONAfterWrite(t *testing.T) {
+	logs := captureLog(t)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("partial"))
+		panic("failed while streaming")
+	}).WithRecoveryJSON()
+	rec := serveRequest(handler, http.MethodGet, "/", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "partial" {
+		t.Errorf("got %d %q, want partial response without problem body", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(logs.String(), "failed while streaming") {
+		t.Error("panic is not logged")
+	}
+}
+
+func TestWithRecoveryJSONAbortHandler(t *testing.T) {
+	captureLog(t)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}).WithRecoveryJSON()
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	serveRequest(handler, http.MethodGet, "/", nil)
+}
@@ -0,0 +1,72 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+/*
+Redirect request to location with code (3xx redirect status, otherwise 500 Internal Server Error status is sent). Location is parsed and written properly encoded (invalid location gets 500 Internal Server Error status). If location is relative and has no query, query of request is preserved. Fragment of location is kept
+Example:
+
+	app.Redirect(rw, r, "/new-path#section", http.StatusMovedPermanently) // /old-path?page=2 is redirected to /new-path?page=2#section
+*/
+func Redirect(w http.ResponseWriter, r *http.Request, location string, code int) {
+	switch code {
+	case http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !u.IsAbs() && u.Host == "" && u.RawQuery == "" && !u.ForceQuery {
+		u.RawQuery = r.URL.RawQuery
+	}
+	http.Redirect(w, r, u.String(), code)
+}
+
+/*
+Validate redirect target from untrusted input (for example, ?next= parameter of login page) against open redirect attacks. Relative url (for example, "/account?tab=1") or absolute http/https url, whose host matches any of allowedHosts (exact host name or wildcard like "*.example.com"), is returned properly encoded with true. Protocol-relative urls ("//evil.com"), backslashes, spaces, control characters and other schemes (for example, "javascript:") are rejected
+Example:
+
+	next, ok := app.SafeRedirectTarget(r.URL.Query().Get("next"), []string{"example.com"})
+	if !ok {
+		next = "/"
+	}
+	app.Redirect(rw, r, next, http.StatusSeeOther)
+*/
+func SafeRedirectTarget(candidate string, allowedHosts []string) (string, bool) {
+	if candidate == "" || strings.HasPrefix(candidate, "//") || strings.ContainsRune(candidate, '\\') {
+		// browsers treat "//host" and "/\host" as absolute url
+		return "", false
+	}
+	for _, c := range candidate {
+		if c < 0x20 || c == 0x7f || c == ' ' {
+			// browsers remove tabs and new lines, so "/\t/evil.com" would become "//evil.com"
+			return "", false
+		}
+	}
+	u, err := url.Parse(candidate)
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return "", false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		return u.String(), true
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range allowedHosts {
+		if matchHost(pattern, host) {
+			return u.String(), true
+		}
+	}
+	return "", false
+}
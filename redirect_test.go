@@ -0,0 +1,92 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		location string
+		code     int
+		want     string
+	}{
+		{"relative keeps query", "/old-path?page=2", "/new-path", http.StatusMovedPermanently, "/new-path?page=2"},
+		{"relative keeps query before fragment", "/old-path?page=2", "/new-path#section", http.StatusFound, "/new-path?page=2#section"},
+		{"relative own query", "/old-path?page=2", "/new-path?tab=1", http.StatusSeeOther, "/new-path?tab=1"},
+		{"relative empty query", "/old-path?page=2", "/new-path?", http.StatusSeeOther, "/new-path?"},
+		{"relative encoded", "/old-path", "/new path", http.StatusTemporaryRedirect, "/new%20path"},
+		{"absolute not changed", "/old-path?page=2", "https://example.org/new-path", http.StatusPermanentRedirect, "https://example.org/new-path"},
+		{"protocol relative not changed", "/old-path?page=2", "//example.org/new-path", http.StatusFound, "//example.org/new-path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Redirect(rec, httptest.NewRequest(http.MethodGet, tt.target, nil), tt.location, tt.code)
+			if rec.Code != tt.code {
+				t.Errorf("status = %d, want %d", rec.Code, tt.code)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedirectInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		code     int
+	}{
+		{"non redirect code", "/new-path", http.StatusOK},
+		{"not modified code", "/new-path", http.StatusNotModified},
+		{"invalid location", "http://[::1", http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Redirect(rec, httptest.NewRequest(http.MethodGet, "/old-path", nil), tt.location, tt.code)
+			if rec.Code != http.StatusInternalServerError || rec.Header().Get("Location") != "" {
+				t.Errorf("got %d with Location %q, want 500 without Location", rec.Code, rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestSafeRedirectTarget(t *testing.T) {
+	allowedHosts := []string{"example.com", "*.example.org"}
+	tests := []struct {
+		candidate string
+		want      string
+		ok        bool
+	}{
+		{"/account?tab=1", "/account?tab=1", true},
+		{"account/settings", "account/settings", true},
+		{"/search?q=a b", "", false},
+		{"/café", "/caf%C3%A9", true},
+		{"https://example.com/welcome", "https://example.com/welcome", true},
+		{"http://EXAMPLE.com:8080/", "http://EXAMPLE.com:8080/", true},
+		{"https://shop.example.org/cart", "https://shop.example.org/cart", true},
+		{"//evil.com", "", false},
+		{"//evil.com/%2e%2e", "", false},
+		{"/\\evil.com", "", false},
+		{"/\t/evil.com", "", false},
+		{"https://evil.com/", "", false},
+		{"https://example.com.evil.com/", "", false},
+		{"https://example.com@evil.com/", "", false},
+		{"https:evil.com", "", false},
+		{"javascript:alert(1)", "", false},
+		{"ftp://example.com/file", "", false},
+		{"", "", false},
+	}
+	for _, test := range tests {
+		got, ok := SafeRedirectTarget(test.candidate, allowedHosts)
+		if got != test.want || ok != test.ok {
+			t.Errorf("SafeRedirectTarget(%q) = %q, %v, want %q, %v", test.candidate, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package webimizer
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+/*
+Error returned by request body reader, when decompressed body exceeds maximum size or compression ratio
+*/
+var ErrDecompressedBodyTooLarge = errors.New("webimizer: decompressed request body is too large")
+
+/*
+Decompressed size, after which compression ratio of request body is checked
+*/
+const ratioCheckMinSize = 64 << 10
+
+/*
+Decompress request body, if Content-Encoding request header is gzip (other encodings get 415 Unsupported Media Type status). To protect from decompression bombs, decompressed body can not be bigger than maxSize bytes and maxRatio times bigger than compressed body (0 means no limit). If body exceeds limits, 413 Request Entity Too Large status is sent (if handler did not write response yet) and body reader returns ErrDecompressedBodyTooLarge
+*/
+func (fn HttpHandler) WithGzipRequestBody(maxSize int64, maxRatio float64) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if encoding == "" || encoding == "identity" {
+			fn(rw, r)
+			return
+		}
+		if encoding != "gzip" {
+			http.Error(rw, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		compressed := &countingReader{r: r.Body}
+		zr, err := gzip.NewReader(compressed)
+		if err != nil {
+			http.Error(rw, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		gw := &guardedResponseWriter{ResponseWriter: rw}
+		body := &limitedGzipReader{zr: zr, compressed: compressed, maxSize: maxSize, maxRatio: maxRatio, w: gw, closer: r.Body}
+		r2 := r.Clone(r.Context())
+		r2.Body = body
+		r2.ContentLength = -1
+		r2.Header.Del("Content-Encoding")
+		r2.Header.Del("Content-Length")
+		fn(gw, r2)
+	})
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += int64(n)
+	return n, err
+}
+
+/*
+Request body reader, which decompresses gzip and checks decompressed size and compression ratio
+*/
+type limitedGzipReader struct {
+	zr         *gzip.Reader
+	compressed *countingReader
+	n          int64
+	maxSize    int64
+	maxRatio   float64
+	w          *guardedResponseWriter
+	closer     io.Closer
+	err        error
+}
+
+func (lr *limitedGzipReader) Read(b []byte) (int, error) {
+	if lr.err != nil {
+		return 0, lr.err
+	}
+	n, err := lr.zr.Read(b)
+	lr.n += int64(n)
+	if lr.exceeded() {
+		lr.err = ErrDecompressedBodyTooLarge
+		lr.w.abort(http.StatusRequestEntityTooLarge)
+		return 0, lr.err
+	}
+	return n, err
+}
+
+func (lr *limitedGzipReader) exceeded() bool {
+	if lr.maxSize > 0 && lr.n > lr.maxSize {
+		return true
+	}
+	return lr.maxRatio > 0 && lr.n > ratioCheckMinSize && float64(lr.n) > lr.maxRatio*float64(lr.compressed.n)
+}
+
+func (lr *limitedGzipReader) Close() error {
+	lr.zr.Close()
+	return lr.closer.Close()
+}
+
+/*
+http.ResponseWriter, which can be aborted with error status: after abort, handler writes are discarded
+*/
+type guardedResponseWriter struct {
+	http.ResponseWriter
+	mu      sync.Mutex
+	written bool
+	aborted bool
+}
+
+func (w *guardedResponseWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.aborted {
+		return
+	}
+	if status >= 200 {
+		w.written = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *guardedResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.aborted {
+		return len(b), nil
+	}
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *guardedResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+/*
+Send error status, if response is not written yet
+*/
+func (w *guardedResponseWriter) abort(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.written || w.aborted {
+		return
+	}
+	w.aborted = true
+	http.Error(w.ResponseWriter, http.StatusText(status), status)
+}
@@ -0,0 +1,92 @@
+package webimizer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	zw.Write(data)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+/*
+Handler, which echoes request body (body read error is reported with X-Read-Error header)
+*/
+func echoBodyHandler(rw http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rw.Header().Set("X-Read-Error", err.Error())
+		rw.WriteHeader(http.StatusUnprocessableEntity)
+		return
+	}
+	rw.Write(body)
+}
+
+func postGzipBody(handler HttpHandler, body []byte, encoding string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body))
+	r.Header.Set("Content-Encoding", encoding)
+	rec := httptest.NewRecorder()
+	handler(rec, r)
+	return rec
+}
+
+func TestWithGzipRequestBody(t *testing.T) {
+	handler := HttpHandler(echoBodyHandler).WithGzipRequestBody(1<<20, 100)
+	rec := postGzipBody(handler, gzipBytes(t, []byte(`{"name":"webimizer"}`)), "gzip")
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"name":"webimizer"}` {
+		t.Errorf("got %d %q, want decompressed body", rec.Code, rec.Body.String())
+	}
+	if rec := postGzipBody(handler, []byte("plain"), ""); rec.Code != http.StatusOK || rec.Body.String() != "plain" {
+		t.Errorf("uncompressed body got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := postGzipBody(handler, []byte("data"), "br"); rec.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("br body got %d, want 415", rec.Code)
+	}
+	if rec := postGzipBody(handler, []byte("not gzip"), "gzip"); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid gzip body got %d, want 400", rec.Code)
+	}
+}
+
+func TestWithGzipRequestBodyBomb(t *testing.T) {
+	// 16 MB of zeros is compressed to about 16 KB (ratio about 1000)
+	bomb := gzipBytes(t, make([]byte, 16<<20))
+	tests := []struct {
+		name     string
+		maxSize  int64
+		maxRatio float64
+	}{
+		{"ratio", 0, 100},
+		{"size", 1 << 20, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readErr error
+			handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+				_, readErr = io.Copy(io.Discard, r.Body)
+				rw.Write([]byte("handler response must be discarded"))
+			}).WithGzipRequestBody(tt.maxSize, tt.maxRatio)
+			rec := postGzipBody(handler, bomb, "gzip")
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d, want 413", rec.Code)
+			}
+			if !errors.Is(readErr, ErrDecompressedBodyTooLarge) {
+				t.Errorf("body read error = %v, want ErrDecompressedBodyTooLarge", readErr)
+			}
+			if bytes.Contains(rec.Body.Bytes(), []byte("handler response")) {
+				t.Error("handler response is sent after abort")
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package webimizer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+/*
+Request header, which contains request ID (it is also set in response)
+*/
+const RequestIDHeader = "X-Request-ID"
+
+/*
+Assign ID to each request: X-Request-ID request header value (if set) or random ID. ID is set in X-Request-ID response header and can be read by RequestID func
+*/
+func (fn HttpHandler) WithRequestID() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			id = newRandomID()
+		}
+		rw.Header().Set(RequestIDHeader, id)
+		fn(rw, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+/*
+Get request ID assigned by WithRequestID (empty string if it is not assigned)
+*/
+func RequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRandomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,34 @@
+package webimizer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+type proxyResponseWriterKey struct{}
+
+/*
+Create HttpHandler, which proxies requests to target (scheme, host and optional base path) with httputil.ReverseProxy. Headers set by upstream replace the same default headers (DefaultHTTPHeaders), other default headers are kept. Upstream compressed responses (with Content-Encoding header) are sent as is, so they are not compressed twice
+Example:
+
+	target, _ := url.Parse("http://127.0.0.1:9000")
+	http.Handle("/api/", app.ReverseProxyHandler(target))
+*/
+func ReverseProxyHandler(target *url.URL) HttpHandler {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ModifyResponse = func(res *http.Response) error {
+		// response headers are added (not replaced) by ReverseProxy, so default header values must be removed first
+		if rw, ok := res.Request.Context().Value(proxyResponseWriterKey{}).(http.ResponseWriter); ok {
+			h := rw.Header()
+			for name := range res.Header {
+				h.Del(name)
+			}
+		}
+		return nil
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		proxy.ServeHTTP(rw, r.WithContext(context.WithValue(r.Context(), proxyResponseWriterKey{}, rw)))
+	})
+}
@@ -0,0 +1,47 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReverseProxyHandler(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	setForTest(t, &DefaultHTTPHeaders, [][]string{{"X-Frame-Options", "DENY"}, {"X-Content-Type-Options", "nosniff"}})
+	content := strings.Repeat("upstream content ", 200)
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Frame-Options", "SAMEORIGIN")
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if r.URL.Path == "/api/compressed" {
+			rw.Header().Set("Content-Encoding", "gzip")
+			rw.Write(gzipBytes(t, []byte(content)))
+			return
+		}
+		rw.Write([]byte(content))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	handler := ReverseProxyHandler(target)
+
+	for _, path := range []string{"/api/plain", "/api/compressed"} {
+		rec := serveRequest(handler, http.MethodGet, path, http.Header{"Accept-Encoding": {"gzip"}})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", path, rec.Code)
+		}
+		if got := rec.Header().Values("Content-Encoding"); len(got) != 1 || got[0] != "gzip" {
+			t.Errorf("%s: Content-Encoding %q, want single gzip", path, got)
+		}
+		if got := gunzip(t, rec.Body.Bytes()); got != content {
+			t.Errorf("%s: body decompressed once is %d bytes, want upstream content", path, len(got))
+		}
+		if got := rec.Header().Values("X-Frame-Options"); len(got) != 1 || got[0] != "SAMEORIGIN" {
+			t.Errorf("%s: X-Frame-Options %q, want upstream value only", path, got)
+		}
+		if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s: default header is not kept", path)
+		}
+	}
+}
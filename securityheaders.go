@@ -0,0 +1,39 @@
+package webimizer
+
+import (
+	"fmt"
+	"strings"
+)
+
+var referrerPolicies = []string{
+	"no-referrer",
+	"no-referrer-when-downgrade",
+	"origin",
+	"origin-when-cross-origin",
+	"same-origin",
+	"strict-origin",
+	"strict-origin-when-cross-origin",
+	"unsafe-url",
+}
+
+/*
+Get Referrer-Policy header pair for DefaultHTTPHeaders. Policy can be one token or comma separated list of fallback tokens, every token is validated
+Example:
+
+	referrerPolicy, err := app.ReferrerPolicyHeader("strict-origin-when-cross-origin")
+	if err != nil {
+		log.Fatal(err)
+	}
+	app.DefaultHTTPHeaders = append(app.DefaultHTTPHeaders, referrerPolicy)
+*/
+func ReferrerPolicyHeader(policy string) ([]string, error) {
+	tokens := strings.Split(policy, ",")
+	for i, token := range tokens {
+		token = strings.ToLower(strings.TrimSpace(token))
+		if !containsString(referrerPolicies, token) {
+			return nil, fmt.Errorf("webimizer: invalid Referrer-Policy token %q", token)
+		}
+		tokens[i] = token
+	}
+	return []string{"Referrer-Policy", strings.Join(tokens, ", ")}, nil
+}
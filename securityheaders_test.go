@@ -0,0 +1,28 @@
+package webimizer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferrerPolicyHeader(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{"no-referrer", []string{"Referrer-Policy", "no-referrer"}},
+		{"strict-origin-when-cross-origin", []string{"Referrer-Policy", "strict-origin-when-cross-origin"}},
+		{"no-referrer, Strict-Origin-When-Cross-Origin", []string{"Referrer-Policy", "no-referrer, strict-origin-when-cross-origin"}},
+	}
+	for _, tt := range tests {
+		got, err := ReferrerPolicyHeader(tt.policy)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReferrerPolicyHeader(%q) = %q, %v, want %q", tt.policy, got, err, tt.want)
+		}
+	}
+	for _, policy := range []string{"", "no-referer", "strict-origin-when-cross-origin, unsafe", "same-origin,"} {
+		if got, err := ReferrerPolicyHeader(policy); err == nil {
+			t.Errorf("ReferrerPolicyHeader(%q) = %q, want error", policy, got)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+)
+
+/*
+Write content with explicit Content-Type (so body is not sniffed by gzipResponseWriter or net/http) and X-Content-Type-Options: nosniff header. Content is streamed to w, so it is compressed by ServeHTTP, if client accepts gzip. If contentType is empty, type is detected as usual
+Example:
+
+	app.ServeContentTyped(rw, "text/csv; charset=utf-8", report)
+*/
+func ServeContentTyped(w http.ResponseWriter, contentType string, content io.Reader) {
+	h := w.Header()
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+		h.Set("X-Content-Type-Options", "nosniff")
+	}
+	io.Copy(w, content)
+}
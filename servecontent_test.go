@@ -0,0 +1,37 @@
+package webimizer
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServeContentTyped(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	// content, which is sniffed as HTML
+	content := "<html>" + strings.Repeat("name,value\n", 500)
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		ServeContentTyped(rw, "text/csv; charset=utf-8", strings.NewReader(content))
+	})
+	rec := serveRequest(handler, http.MethodGet, "/report.csv", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("response is not compressed")
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/csv; charset=utf-8", got)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("X-Content-Type-Options: nosniff is not set")
+	}
+	if gunzip(t, rec.Body.Bytes()) != content {
+		t.Error("decompressed body differs from content")
+	}
+
+	untyped := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		ServeContentTyped(rw, "", strings.NewReader(content))
+	})
+	rec = serveRequest(untyped, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}})
+	if got := rec.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+		t.Errorf("empty content type: Content-Type = %q, want sniffed text/html", got)
+	}
+}
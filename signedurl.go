@@ -0,0 +1,70 @@
+package webimizer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// Error returned by ValidateSignedURL, when sig query parameter is missing or does not match
+	ErrInvalidURLSignature = errors.New("webimizer: invalid URL signature")
+	// Error returned by ValidateSignedURL, when exp query parameter is missing, invalid or in the past
+	ErrURLExpired = errors.New("webimizer: signed URL is expired")
+)
+
+/*
+Sign url base (absolute url or path with optional query) for time-limited access: exp (expiry unix time) and sig (HMAC-SHA256 of path and query with secret) query parameters are added. Host is not signed, so url stays valid behind proxies. If base can not be parsed, empty string is returned
+Example:
+
+	link := app.SignURL("/downloads/report.pdf", time.Now().Add(time.Hour), secret)
+*/
+func SignURL(base string, expiry time.Time, secret []byte) string {
+	u, err := url.Parse(base)
+	if err != nil {
+		return ""
+	}
+	query := u.Query()
+	query.Del("sig")
+	query.Set("exp", strconv.FormatInt(expiry.Unix(), 10))
+	query.Set("sig", urlSignature(u.EscapedPath(), query, secret))
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+/*
+Validate request url signed by SignURL: ErrInvalidURLSignature is returned, if path or query was changed, ErrURLExpired is returned, if url is expired
+Example:
+
+	if err := app.ValidateSignedURL(r, secret); err != nil {
+		http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+*/
+func ValidateSignedURL(r *http.Request, secret []byte) error {
+	query := r.URL.Query()
+	sig := query.Get("sig")
+	query.Del("sig")
+	if sig == "" || !hmac.Equal([]byte(sig), []byte(urlSignature(r.URL.EscapedPath(), query, secret))) {
+		return ErrInvalidURLSignature
+	}
+	exp, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+/*
+Get signature of path and query (without sig parameter, parameters are sorted by url.Values.Encode)
+*/
+func urlSignature(path string, query url.Values, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(path + "?" + query.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
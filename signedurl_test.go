@@ -0,0 +1,40 @@
+package webimizer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignedURL(t *testing.T) {
+	secret := []byte("secret")
+	valid := SignURL("/downloads/report.pdf?format=a4", time.Now().Add(time.Hour), secret)
+	expired := SignURL("/downloads/report.pdf", time.Now().Add(-time.Minute), secret)
+	tests := []struct {
+		name   string
+		target string
+		secret []byte
+		want   error
+	}{
+		{"valid", valid, secret, nil},
+		{"valid absolute", SignURL("https://cdn.example.com/a.zip", time.Now().Add(time.Hour), secret), secret, nil},
+		{"expired", expired, secret, ErrURLExpired},
+		{"tampered path", strings.Replace(valid, "report.pdf", "secret.pdf", 1), secret, ErrInvalidURLSignature},
+		{"tampered query", strings.Replace(valid, "format=a4", "format=a3", 1), secret, ErrInvalidURLSignature},
+		{"extended expiry", strings.Replace(expired, "exp=", "exp=9", 1), secret, ErrInvalidURLSignature},
+		{"added parameter", valid + "&admin=1", secret, ErrInvalidURLSignature},
+		{"other secret", valid, []byte("other"), ErrInvalidURLSignature},
+		{"unsigned", "/downloads/report.pdf", secret, ErrInvalidURLSignature},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, test.target, nil)
+		if err := ValidateSignedURL(r, test.secret); err != test.want {
+			t.Errorf("%s: ValidateSignedURL(%q) = %v, want %v", test.name, test.target, err, test.want)
+		}
+	}
+	if got := SignURL("%zz", time.Now(), secret); got != "" {
+		t.Errorf("SignURL of invalid url = %q, want empty string", got)
+	}
+}
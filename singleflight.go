@@ -0,0 +1,66 @@
+package webimizer
+
+import (
+	"net/http"
+	"sync"
+)
+
+type flightCall struct {
+	wg       sync.WaitGroup
+	response *capturedResponse
+	panicked bool
+}
+
+/*
+Coalesce concurrent GET and HEAD requests with the same key (returned by keyFn): handler runs once per key and its buffered response is sent to all waiting clients. Set-Cookie headers are sent only to the client, whose request was handled. If keyFn returns empty string, request is not coalesced
+Example:
+
+	handler.WithSingleFlight(func(r *http.Request) string { return r.URL.String() })
+*/
+func (fn HttpHandler) WithSingleFlight(keyFn func(*http.Request) string) HttpHandler {
+	var mu sync.Mutex
+	calls := map[string]*flightCall{}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			fn(rw, r)
+			return
+		}
+		key := keyFn(r)
+		if key == "" {
+			fn(rw, r)
+			return
+		}
+		key = r.Method + " " + key
+		mu.Lock()
+		if call, ok := calls[key]; ok {
+			mu.Unlock()
+			call.wg.Wait()
+			if call.panicked {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			call.response.writeTo(rw, "Set-Cookie")
+			return
+		}
+		call := &flightCall{response: newCapturedResponse(), panicked: true}
+		call.wg.Add(1)
+		calls[key] = call
+		mu.Unlock()
+		done := func() {
+			mu.Lock()
+			delete(calls, key)
+			mu.Unlock()
+			call.wg.Done()
+		}
+		defer func() {
+			if call.panicked {
+				done()
+			}
+		}()
+		fn(call.response, r)
+		call.panicked = false
+		// release waiting clients before writing to (possibly slow) leader client
+		done()
+		call.response.writeTo(rw)
+	})
+}
@@ -0,0 +1,138 @@
+package webimizer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithSingleFlight(t *testing.T) {
+	const n = 10
+	var runs, keys atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if runs.Add(1) == 1 {
+			close(started)
+		}
+		<-release
+		http.SetCookie(rw, &http.Cookie{Name: "session", Value: "first"})
+		rw.Header().Set("X-Computed", "yes")
+		io.WriteString(rw, "expensive result")
+	}).WithSingleFlight(func(r *http.Request) string {
+		keys.Add(1)
+		return r.URL.Path
+	})
+
+	type result struct {
+		status int
+		body   string
+		cookie string
+		header string
+	}
+	results := make(chan result, n)
+	var wg sync.WaitGroup
+	request := func() {
+		defer wg.Done()
+		rec := serveRequest(handler, http.MethodGet, "/report", nil)
+		results <- result{rec.Code, rec.Body.String(), rec.Header().Get("Set-Cookie"), rec.Header().Get("X-Computed")}
+	}
+	wg.Add(1)
+	go request()
+	<-started
+	for i := 1; i < n; i++ {
+		wg.Add(1)
+		go request()
+	}
+	for keys.Load() < n {
+		time.Sleep(time.Millisecond)
+	}
+	// give waiting requests time to join in-flight call after key is computed
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	if got := runs.Load(); got != 1 {
+		t.Errorf("handler ran %d times, want 1", got)
+	}
+	cookies := 0
+	for res := range results {
+		if res.status != http.StatusOK || res.body != "expensive result" || res.header != "yes" {
+			t.Errorf("got %d %q X-Computed %q, want shared response", res.status, res.body, res.header)
+		}
+		if res.cookie != "" {
+			cookies++
+		}
+	}
+	if cookies != 1 {
+		t.Errorf("Set-Cookie sent to %d clients, want 1", cookies)
+	}
+
+	serveRequest(handler, http.MethodGet, "/report", nil)
+	if got := runs.Load(); got != 2 {
+		t.Errorf("handler ran %d times after in-flight call finished, want 2", got)
+	}
+}
+
+/*
+http.ResponseWriter, which blocks body writes until unblock is closed
+*/
+type slowResponseWriter struct {
+	*httptest.ResponseRecorder
+	unblock chan struct{}
+}
+
+func (w *slowResponseWriter) Write(b []byte) (int, error) {
+	<-w.unblock
+	return w.ResponseRecorder.Write(b)
+}
+
+func TestWithSingleFlightSlowLeader(t *testing.T) {
+	var keys atomic.Int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(rw, "shared")
+	}).WithSingleFlight(func(r *http.Request) string {
+		keys.Add(1)
+		return r.URL.Path
+	})
+
+	leader := &slowResponseWriter{httptest.NewRecorder(), make(chan struct{})}
+	leaderDone := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(leader, httptest.NewRequest(http.MethodGet, "/report", nil))
+		close(leaderDone)
+	}()
+	<-started
+	waiter := make(chan *httptest.ResponseRecorder)
+	go func() {
+		waiter <- serveRequest(handler, http.MethodGet, "/report", nil)
+	}()
+	for keys.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case rec := <-waiter:
+		if rec.Body.String() != "shared" {
+			t.Errorf("waiter got %q, want %q", rec.Body.String(), "shared")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("waiter blocked by slow leader client")
+	}
+	close(leader.unblock)
+	<-leaderDone
+	if leader.Body.String() != "shared" {
+		t.Errorf("leader got %q, want %q", leader.Body.String(), "shared")
+	}
+}
@@ -0,0 +1,61 @@
+package webimizer
+
+import (
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+/*
+Define log level of request record by response status (used by WithSlog). By default 5xx responses are logged with warn level, others with info level
+*/
+var SlogLevel = func(status int) slog.Level {
+	if status >= 500 {
+		return slog.LevelWarn
+	}
+	return slog.LevelInfo
+}
+
+/*
+Log each request with structured attributes: method, path, status, duration, bytes, remote_addr (ClientIP) and request_id (if assigned by WithRequestID, which can wrap WithSlog or be wrapped by it: if it is inside, ID is read from X-Request-ID response header)
+*/
+func (fn HttpHandler) WithSlog(logger *slog.Logger) HttpHandler {
+	return fn.WithSlogSampling(logger, 1)
+}
+
+/*
+Log every n-th request (like WithSlog) to reduce log volume on high-traffic endpoints. 5xx responses are always logged. If n < 2, all requests are logged
+Example:
+
+	handler.WithSlogSampling(slog.Default(), 100) // log 1 of 100 requests and all errors
+*/
+func (fn HttpHandler) WithSlogSampling(logger *slog.Logger, n int) HttpHandler {
+	var counter atomic.Uint64
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &countingResponseWriter{ResponseWriter: rw}
+		fn(sw, r)
+		sampled := n < 2 || counter.Add(1)%uint64(n) == 1
+		if !sampled && sw.Status() < 500 {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.Status()),
+			slog.Duration("duration", time.Since(start)),
+			slog.Int64("bytes", sw.BytesWritten()),
+			slog.String("remote_addr", ClientIP(r)),
+		}
+		id := RequestID(r)
+		if id == "" {
+			// WithRequestID is called inside, so ID is only in response header
+			id = sw.Header().Get(RequestIDHeader)
+		}
+		if id != "" {
+			attrs = append(attrs, slog.String("request_id", id))
+		}
+		logger.LogAttrs(r.Context(), SlogLevel(sw.Status()), "http request", attrs...)
+	})
+}
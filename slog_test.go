@@ -0,0 +1,135 @@
+package webimizer
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+/*
+slog.Handler, which captures log records
+*/
+type captureSlogHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *captureSlogHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *captureSlogHandler) Handle(_ context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, record.Clone())
+	return nil
+}
+
+func (h *captureSlogHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+func (h *captureSlogHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+func recordAttrs(record slog.Record) map[string]slog.Value {
+	attrs := map[string]slog.Value{}
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs[attr.Key] = attr.Value
+		return true
+	})
+	return attrs
+}
+
+func TestWithSlog(t *testing.T) {
+	capture := &captureSlogHandler{}
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(rw, "failed", http.StatusBadGateway)
+			return
+		}
+		rw.Write([]byte("hello"))
+	}).WithSlog(slog.New(capture)).WithRequestID()
+
+	serveRequest(handler, http.MethodPost, "/page", http.Header{RequestIDHeader: {"req-1"}})
+	serveRequest(handler, http.MethodGet, "/fail", nil)
+	if len(capture.records) != 2 {
+		t.Fatalf("got %d log records, want 2", len(capture.records))
+	}
+
+	record := capture.records[0]
+	if record.Level != slog.LevelInfo || record.Message != "http request" {
+		t.Errorf("record = %v %q, want INFO http request", record.Level, record.Message)
+	}
+	attrs := recordAttrs(record)
+	for key, want := range map[string]string{"method": "POST", "path": "/page", "remote_addr": "192.0.2.1", "request_id": "req-1"} {
+		if got := attrs[key].String(); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if got := attrs["status"].Int64(); got != http.StatusOK {
+		t.Errorf("status = %d, want 200", got)
+	}
+	if got := attrs["bytes"].Int64(); got != 5 {
+		t.Errorf("bytes = %d, want 5", got)
+	}
+	if attrs["duration"].Kind() != slog.KindDuration {
+		t.Errorf("duration attribute kind = %v", attrs["duration"].Kind())
+	}
+
+	record = capture.records[1]
+	if record.Level != slog.LevelWarn || recordAttrs(record)["status"].Int64() != http.StatusBadGateway {
+		t.Errorf("5xx record = %v status %v, want WARN 502", record.Level, recordAttrs(record)["status"])
+	}
+	if id := recordAttrs(record)["request_id"].String(); id == "" {
+		t.Error("generated request ID is not logged")
+	}
+}
+
+func TestWithSlogInsideRequestID(t *testing.T) {
+	capture := &captureSlogHandler{}
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Write([]byte("hello"))
+	}).WithRequestID().WithSlog(slog.New(capture))
+
+	serveRequest(handler, http.MethodGet, "/page", http.Header{RequestIDHeader: {"req-2"}})
+	if len(capture.records) != 1 {
+		t.Fatalf("got %d log records, want 1", len(capture.records))
+	}
+	if got := recordAttrs(capture.records[0])["request_id"].String(); got != "req-2" {
+		t.Errorf("request_id = %q, want req-2", got)
+	}
+}
+
+func TestWithSlogSampling(t *testing.T) {
+	capture := &captureSlogHandler{}
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(rw, "failed", http.StatusInternalServerError)
+			return
+		}
+		rw.Write([]byte("hello"))
+	}).WithSlogSampling(slog.New(capture), 10)
+
+	for i := 0; i < 100; i++ {
+		serveRequest(handler, http.MethodGet, "/page", nil)
+	}
+	if len(capture.records) != 10 {
+		t.Errorf("got %d log records for 100 requests, want 10", len(capture.records))
+	}
+	capture.records = nil
+	for i := 0; i < 5; i++ {
+		serveRequest(handler, http.MethodGet, "/fail", nil)
+	}
+	if len(capture.records) != 5 {
+		t.Fatalf("got %d log records for 5 failed requests, want 5", len(capture.records))
+	}
+	for _, record := range capture.records {
+		if status := recordAttrs(record)["status"].Int64(); status != http.StatusInternalServerError || record.Level != slog.LevelWarn {
+			t.Errorf("record status %d level %v, want 500 at warn level", status, record.Level)
+		}
+	}
+}
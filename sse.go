@@ -0,0 +1,58 @@
+package webimizer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+/*
+Create HttpHandler for streaming Server-Sent Events. fn is called with request context (it is cancelled when client disconnects) and send func, which writes event (event name can be empty) and flushes it to client. Response is not compressed
+Example:
+
+	app.SSEHandler(func(ctx context.Context, send func(event, data string) error) {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case t := <-ticker.C:
+				if send("tick", t.String()) != nil {
+					return
+				}
+			}
+		}
+	})
+*/
+func SSEHandler(fn func(ctx context.Context, send func(event, data string) error)) HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		h := rw.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Del("Content-Length")
+		disableGzip(rw)
+		rw.WriteHeader(http.StatusOK)
+		flushResponse(rw)
+		ctx := r.Context()
+		fn(ctx, func(event, data string) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var b strings.Builder
+			if event != "" {
+				fmt.Fprintf(&b, "event: %s\n", event)
+			}
+			for _, line := range strings.Split(data, "\n") {
+				fmt.Fprintf(&b, "data: %s\n", line)
+			}
+			b.WriteString("\n")
+			if _, err := rw.Write([]byte(b.String())); err != nil {
+				return err
+			}
+			flushResponse(rw)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package webimizer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEHandler(t *testing.T) {
+	stopped := make(chan struct{})
+	handler := SSEHandler(func(ctx context.Context, send func(event, data string) error) {
+		defer close(stopped)
+		send("greeting", "hello")
+		send("", "line 1\nline 2")
+		// stream is open until client disconnects
+		<-ctx.Done()
+		if send("late", "data") == nil {
+			t.Error("send after client disconnect returns nil error")
+		}
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	res, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	if res.Uncompressed || res.Header.Get("Content-Encoding") != "" {
+		t.Error("event stream is compressed")
+	}
+	reader := bufio.NewReader(res.Body)
+	var events []string
+	for len(events) < 2 {
+		var event strings.Builder
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read event %d: %v", len(events)+1, err)
+			}
+			if line == "\n" {
+				break
+			}
+			event.WriteString(line)
+		}
+		events = append(events, event.String())
+	}
+	if want := "event: greeting\ndata: hello\n"; events[0] != want {
+		t.Errorf("first event = %q, want %q", events[0], want)
+	}
+	if want := "data: line 1\ndata: line 2\n"; events[1] != want {
+		t.Errorf("second event = %q, want %q", events[1], want)
+	}
+
+	res.Body.Close()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("event stream is not stopped after client disconnect")
+	}
+}
+
+func TestSSEHandlerIdentityForbidden(t *testing.T) {
+	server := httptest.NewServer(SSEHandler(func(ctx context.Context, send func(event, data string) error) {
+		send("greeting", "hello")
+		<-ctx.Done()
+	}))
+	defer server.Close()
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip, identity;q=0")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("got %d %q, want compressed event stream", res.StatusCode, res.Header.Get("Content-Encoding"))
+	}
+	gz, err := gzip.NewReader(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// event is flushed from gzip stream, while stream is still open
+	reader := bufio.NewReader(gz)
+	for _, want := range []string{"event: greeting\n", "data: hello\n"} {
+		if line, err := reader.ReadString('\n'); err != nil || line != want {
+			t.Fatalf("line %q, error %v, want %q", line, err, want)
+		}
+	}
+}
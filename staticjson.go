@@ -0,0 +1,31 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*
+Create HttpHandler, which serves v marshaled to JSON. JSON is compressed once (with GzipCompressionLevel), so gzip compressed body is served to clients accepting gzip and uncompressed body to others. ETag header is computed from content, so conditional GET and HEAD requests get 304 Not Modified status
+Example:
+
+	handler, err := app.StaticJSONHandler(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	http.Handle("/config.json", handler)
+*/
+func StaticJSONHandler(v interface{}) (HttpHandler, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	content, err := newStaticContent(body)
+	if err != nil {
+		return nil, err
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		content.serve(rw, r, http.StatusOK)
+	}), nil
+}
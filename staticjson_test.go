@@ -0,0 +1,49 @@
+package webimizer
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestStaticJSONHandler(t *testing.T) {
+	handler, err := StaticJSONHandler(map[string]interface{}{"feature": true, "name": "webimizer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"feature":true,"name":"webimizer"}`
+
+	plain := serveRequest(handler, http.MethodGet, "/config.json", nil)
+	if plain.Code != http.StatusOK || plain.Body.String() != want || plain.Header().Get("Content-Encoding") != "" {
+		t.Errorf("plain got %d %q %q", plain.Code, plain.Header().Get("Content-Encoding"), plain.Body.String())
+	}
+	gzipped := serveRequest(handler, http.MethodGet, "/config.json", http.Header{"Accept-Encoding": {"gzip"}})
+	if gzipped.Code != http.StatusOK || gzipped.Header().Get("Content-Encoding") != "gzip" || gunzip(t, gzipped.Body.Bytes()) != want {
+		t.Errorf("gzip got %d %q", gzipped.Code, gzipped.Header().Get("Content-Encoding"))
+	}
+	for _, rec := range []interface{ Header() http.Header }{plain, gzipped} {
+		if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Content-Type %q Vary %q, want application/json and Accept-Encoding", rec.Header().Get("Content-Type"), rec.Header().Get("Vary"))
+		}
+	}
+	plainETag, gzipETag := plain.Header().Get("ETag"), gzipped.Header().Get("ETag")
+	if plainETag == "" || plainETag == gzipETag {
+		t.Fatalf("ETags %q and %q, want different ETags of representations", plainETag, gzipETag)
+	}
+
+	rec := serveRequest(handler, http.MethodGet, "/config.json", http.Header{"Accept-Encoding": {"gzip"}, "If-None-Match": {gzipETag}})
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("conditional gzip request got %d with %d body bytes, want 304", rec.Code, rec.Body.Len())
+	}
+	rec = serveRequest(handler, http.MethodGet, "/config.json", http.Header{"If-None-Match": {plainETag}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional plain request got %d, want 304", rec.Code)
+	}
+	rec = serveRequest(handler, http.MethodGet, "/config.json", http.Header{"If-None-Match": {gzipETag}})
+	if rec.Code != http.StatusOK {
+		t.Errorf("plain request with ETag of gzip representation got %d, want 200", rec.Code)
+	}
+
+	if _, err := StaticJSONHandler(func() {}); err == nil {
+		t.Error("value, which can not be marshaled, is accepted")
+	}
+}
@@ -0,0 +1,23 @@
+package webimizer
+
+import (
+	"net/http"
+)
+
+/*
+Prepare streaming response: Content-Length header is removed (so response is sent with chunked transfer encoding over HTTP/1.1), gzip compression is not disabled by Content-Length and status with headers are sent to client immediately. Call it after response headers are set. Call Flush of http.ResponseWriter (or http.NewResponseController(w).Flush()) after each written part, so it is sent (compressed part is flushed from gzip stream too)
+Example:
+
+	rw.Header().Set("Content-Type", "application/x-ndjson")
+	app.StreamResponse(rw)
+	for item := range items {
+		json.NewEncoder(rw).Encode(item)
+		http.NewResponseController(rw).Flush()
+	}
+*/
+func StreamResponse(w http.ResponseWriter) {
+	w.Header().Del("Content-Length")
+	EnableGzipStreaming(w)
+	w.WriteHeader(http.StatusOK)
+	flushResponse(w)
+}
@@ -0,0 +1,64 @@
+package webimizer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStreamResponse(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	release := make(chan struct{})
+	server := httptest.NewServer(HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+		rw.Header().Set("Content-Length", "1000")
+		StreamResponse(rw)
+		io.WriteString(rw, "{\"part\":1}\n")
+		http.NewResponseController(rw).Flush()
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+		io.WriteString(rw, "{\"part\":2}\n")
+	}))
+	defer server.Close()
+
+	for _, acceptEncoding := range []string{"identity", "gzip"} {
+		t.Run(acceptEncoding, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.ContentLength != -1 || len(res.TransferEncoding) != 1 || res.TransferEncoding[0] != "chunked" {
+				t.Errorf("Content-Length %d, Transfer-Encoding %v, want chunked", res.ContentLength, res.TransferEncoding)
+			}
+			var body io.Reader = res.Body
+			if acceptEncoding == "gzip" {
+				if res.Header.Get("Content-Encoding") != "gzip" {
+					t.Fatal("stream is not compressed")
+				}
+				gz, err := gzip.NewReader(res.Body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gz
+			}
+			lines := bufio.NewReader(body)
+			// first part is read while handler is still waiting
+			if line, err := lines.ReadString('\n'); err != nil || line != "{\"part\":1}\n" {
+				t.Fatalf("first part %q, error %v", line, err)
+			}
+			release <- struct{}{}
+			if line, err := lines.ReadString('\n'); err != nil || line != "{\"part\":2}\n" {
+				t.Errorf("second part %q, error %v", line, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package webimizer
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+Set Cache-Control response header, which allows caches to use response for maxAge and to serve stale response for staleWindow more while it is revalidated in background (RFC 5861). Durations are rounded down to seconds
+Example:
+
+	app.SetStaleWhileRevalidate(rw, time.Minute, 10*time.Minute) // Cache-Control: max-age=60, stale-while-revalidate=600
+*/
+func SetStaleWhileRevalidate(w http.ResponseWriter, maxAge, staleWindow time.Duration) {
+	value := "max-age=" + strconv.FormatInt(int64(maxAge/time.Second), 10)
+	if staleWindow >= time.Second {
+		value += ", stale-while-revalidate=" + strconv.FormatInt(int64(staleWindow/time.Second), 10)
+	}
+	w.Header().Set("Cache-Control", value)
+}
+
+/*
+Get max-age and stale-while-revalidate directives of Cache-Control response header. false is returned, if response can not be stored in shared cache (no-store, no-cache or private directive is set or max-age is missing)
+*/
+func sharedCacheLifetime(h http.Header) (maxAge, staleWindow time.Duration, ok bool) {
+	for _, directive := range strings.Split(strings.Join(h.Values("Cache-Control"), ","), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache", "private":
+			return 0, 0, false
+		case "max-age":
+			if err != nil || seconds < 0 {
+				return 0, 0, false
+			}
+			maxAge, ok = time.Duration(seconds)*time.Second, true
+		case "stale-while-revalidate":
+			if err == nil && seconds > 0 {
+				staleWindow = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return maxAge, staleWindow, ok
+}
+
+/*
+Define maximum number of responses, which are cached by each WithStaleWhileRevalidate handler (keys are often controlled by client, so cache must not grow forever). When cache is full, expired responses are removed and then the oldest response is evicted. If it is 0 or less, number of responses is not limited
+*/
+var StaleWhileRevalidateMaxEntries = 1000
+
+type swrEntry struct {
+	response    *capturedResponse
+	stored      time.Time
+	maxAge      time.Duration
+	staleWindow time.Duration
+	refreshing  bool
+}
+
+/*
+Remove expired entries and then the oldest entries, until at most n entries are left
+*/
+func evictSWREntries(entries map[string]*swrEntry, n int) {
+	now := time.Now()
+	for key, entry := range entries {
+		if now.Sub(entry.stored) >= entry.maxAge+entry.staleWindow {
+			delete(entries, key)
+		}
+	}
+	for len(entries) > n {
+		oldest := ""
+		for key, entry := range entries {
+			if oldest == "" || entry.stored.Before(entries[oldest].stored) {
+				oldest = key
+			}
+		}
+		delete(entries, oldest)
+	}
+}
+
+/*
+Cache responses of GET and HEAD requests with the same key (returned by keyFn) in memory, as long as Cache-Control response header allows it (set it by SetStaleWhileRevalidate). Fresh responses are served from cache, stale responses (in stale-while-revalidate window) are served from cache immediately and handler is called in background to refresh them. Only 200 OK responses without Set-Cookie header are cached. If keyFn returns empty string, request is not cached. At most StaleWhileRevalidateMaxEntries responses are cached. Concurrent cache misses are not coalesced (use WithSingleFlight inside)
+Example:
+
+	handler.WithStaleWhileRevalidate(func(r *http.Request) string { return r.URL.RequestURI() })
+*/
+func (fn HttpHandler) WithStaleWhileRevalidate(keyFn func(*http.Request) string) HttpHandler {
+	var mu sync.Mutex
+	entries := map[string]*swrEntry{}
+	// call handler and store its response, if it is cacheable (entry of key is removed otherwise)
+	fetch := func(key string, r *http.Request) *capturedResponse {
+		response := newCapturedResponse()
+		fn(response, r)
+		maxAge, staleWindow, ok := sharedCacheLifetime(response.header)
+		mu.Lock()
+		defer mu.Unlock()
+		if ok && response.status == http.StatusOK && len(response.header.Values("Set-Cookie")) == 0 {
+			if _, exists := entries[key]; !exists && StaleWhileRevalidateMaxEntries > 0 && len(entries) >= StaleWhileRevalidateMaxEntries {
+				evictSWREntries(entries, StaleWhileRevalidateMaxEntries-1)
+			}
+			entries[key] = &swrEntry{response: response, stored: time.Now(), maxAge: maxAge, staleWindow: staleWindow}
+		} else {
+			delete(entries, key)
+		}
+		return response
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			fn(rw, r)
+			return
+		}
+		key := keyFn(r)
+		if key == "" {
+			fn(rw, r)
+			return
+		}
+		key = r.Method + " " + key
+		mu.Lock()
+		entry, ok := entries[key]
+		var age time.Duration
+		if ok {
+			age = time.Since(entry.stored)
+			if age >= entry.maxAge+entry.staleWindow {
+				delete(entries, key)
+				ok = false
+			} else if age >= entry.maxAge && !entry.refreshing {
+				entry.refreshing = true
+				// refresh must not be canceled, when client of stale response disconnects
+				req := r.Clone(context.WithoutCancel(r.Context()))
+				go func() {
+					defer func() {
+						if err := recover(); err != nil {
+							log.Printf("webimizer: panic refreshing stale response %s: %v", key, err)
+							mu.Lock()
+							entry.refreshing = false
+							mu.Unlock()
+						}
+					}()
+					fetch(key, req)
+				}()
+			}
+		}
+		mu.Unlock()
+		if !ok {
+			fetch(key, r).writeTo(rw)
+			return
+		}
+		rw.Header().Set("Age", strconv.FormatInt(int64(age/time.Second), 10))
+		entry.response.writeTo(rw)
+	})
+}
@@ -0,0 +1,77 @@
+package webimizer
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithStaleWhileRevalidate(t *testing.T) {
+	var calls atomic.Int64
+	release := make(chan struct{})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if n == 2 {
+			// background refresh is slow
+			<-release
+		}
+		// max-age=0, so every cached response is stale at once
+		SetStaleWhileRevalidate(rw, 0, time.Minute)
+		rw.Write([]byte("v" + strconv.FormatInt(n, 10)))
+	}).WithStaleWhileRevalidate(func(r *http.Request) string { return r.URL.RequestURI() })
+
+	if rec := serveRequest(handler, http.MethodGet, "/data", nil); rec.Body.String() != "v1" {
+		t.Fatalf("first request got %q, want v1", rec.Body.String())
+	}
+	done := make(chan string, 1)
+	go func() {
+		done <- serveRequest(handler, http.MethodGet, "/data", nil).Body.String()
+	}()
+	select {
+	case body := <-done:
+		if body != "v1" {
+			t.Errorf("stale request got %q, want cached v1", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stale response is not served immediately")
+	}
+	close(release)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		body := serveRequest(handler, http.MethodGet, "/data", nil).Body.String()
+		if body == "v2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q after refresh, want v2", body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec := serveRequest(handler, http.MethodPost, "/data", nil); rec.Header().Get("Age") != "" {
+		t.Error("POST response is served from cache")
+	}
+}
+
+func TestWithStaleWhileRevalidateMaxEntries(t *testing.T) {
+	setForTest(t, &StaleWhileRevalidateMaxEntries, 2)
+	calls := map[string]int{}
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		calls[r.URL.Path]++
+		SetStaleWhileRevalidate(rw, time.Minute, 0)
+		rw.Write([]byte(r.URL.Path))
+	}).WithStaleWhileRevalidate(func(r *http.Request) string { return r.URL.RequestURI() })
+
+	for _, path := range []string{"/a", "/b", "/c"} {
+		serveRequest(handler, http.MethodGet, path, nil)
+		time.Sleep(time.Millisecond)
+	}
+	if rec := serveRequest(handler, http.MethodGet, "/c", nil); rec.Header().Get("Age") == "" || calls["/c"] != 1 {
+		t.Errorf("newest response is not served from cache (handler called %d times)", calls["/c"])
+	}
+	if rec := serveRequest(handler, http.MethodGet, "/a", nil); rec.Header().Get("Age") != "" || calls["/a"] != 2 {
+		t.Errorf("oldest response is not evicted (handler called %d times)", calls["/a"])
+	}
+}
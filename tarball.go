@@ -0,0 +1,116 @@
+package webimizer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+/*
+Create HttpHandler, which streams root directory of fsys (with all subdirectories) as .tar.gz archive (Content-Type: application/gzip, Content-Disposition: attachment). Archive is written while files are read, so whole archive is never kept in memory. Files and directories with names starting with dot (for example, .git or .env) are not archived, except /.well-known directory (as with FileServerStruct.HideDotFiles). If root is not a directory (or it is hidden), 404 status is returned
+Example:
+
+	http.Handle("/download.tar.gz", app.TarballHandler(http.Dir("./public"), "/docs"))
+*/
+func TarballHandler(fsys http.FileSystem, root string) HttpHandler {
+	root = cleanPath(root)
+	name := path.Base(root)
+	if name == "/" {
+		name = "download"
+	}
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if isHiddenPath(root) {
+			http.NotFound(rw, r)
+			return
+		}
+		dir, err := fsys.Open(root)
+		if err != nil {
+			http.NotFound(rw, r)
+			return
+		}
+		s, err := dir.Stat()
+		dir.Close()
+		if err != nil || !s.IsDir() {
+			http.NotFound(rw, r)
+			return
+		}
+		h := rw.Header()
+		h.Set("Content-Type", "application/gzip")
+		h.Set("Content-Disposition", `attachment; filename="`+name+`.tar.gz"`)
+		// archive is already compressed
+		disableGzip(rw)
+		if r.Method == http.MethodHead {
+			return
+		}
+		gz, err := gzip.NewWriterLevel(rw, GzipCompressionLevel)
+		if err != nil {
+			gz = gzip.NewWriter(rw)
+		}
+		tw := tar.NewWriter(gz)
+		// status is already sent, so error can only truncate archive
+		if err := writeTarDir(tw, fsys, root, ""); err != nil {
+			return
+		}
+		if err := tw.Close(); err != nil {
+			return
+		}
+		gz.Close()
+	})
+}
+
+/*
+Write files of directory dir (with subdirectories) to tar archive under prefix. Hidden files and directories are skipped
+*/
+func writeTarDir(tw *tar.Writer, fsys http.FileSystem, dir, prefix string) error {
+	d, err := fsys.Open(dir)
+	if err != nil {
+		return err
+	}
+	entries, err := d.Readdir(-1)
+	d.Close()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		filePath := path.Join(dir, entry.Name())
+		if isHiddenPath(filePath) {
+			continue
+		}
+		name := strings.TrimPrefix(path.Join(prefix, entry.Name()), "/")
+		header, err := tar.FileInfoHeader(entry, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if entry.IsDir() {
+			header.Name += "/"
+			if err := tw.WriteHeader(header); err != nil {
+				return err
+			}
+			if err := writeTarDir(tw, fsys, filePath, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if !entry.Mode().IsRegular() {
+			continue
+		}
+		f, err := fsys.Open(filePath)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			f.Close()
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
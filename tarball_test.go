@@ -0,0 +1,106 @@
+package webimizer
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+/*
+Read entries of .tar.gz archive (entry name to content)
+*/
+func readTarball(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[header.Name] = string(content)
+	}
+	return files
+}
+
+func TestTarballHandler(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "docs/index.html", "<h1>Docs</h1>")
+	writeTestFile(t, dir, "docs/img/logo.svg", "<svg></svg>")
+	writeTestFile(t, dir, "other.txt", "not in archive")
+	writeTestFile(t, dir, "docs/.env", "SECRET=1")
+	writeTestFile(t, dir, "docs/.git/config", "[core]")
+	writeTestFile(t, dir, "docs/img/.hidden.svg", "<svg></svg>")
+	handler := TarballHandler(http.Dir(dir), "/docs")
+
+	rec := serveRequest(handler, http.MethodGet, "/download.tar.gz", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/gzip" {
+		t.Fatalf("status %d, Content-Type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="docs.tar.gz"` {
+		t.Errorf("Content-Disposition %q", got)
+	}
+	if rec.Header().Get("Content-Encoding") != "" {
+		t.Error("archive is compressed twice")
+	}
+	files := readTarball(t, rec.Body)
+	want := map[string]string{"img/": "", "img/logo.svg": "<svg></svg>", "index.html": "<h1>Docs</h1>"}
+	if len(files) != len(want) {
+		t.Errorf("archive has %d entries, want %d: %v", len(files), len(want), files)
+	}
+	for name, content := range want {
+		if got, ok := files[name]; !ok || got != content {
+			t.Errorf("entry %q = %q (present %v), want %q", name, got, ok, content)
+		}
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/download.tar.gz", http.Header{"Accept-Encoding": {"gzip, identity;q=0"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("identity;q=0 got %d %q, want compressed archive", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if gz, err := gzip.NewReader(strings.NewReader(gunzip(t, rec.Body.Bytes()))); err != nil {
+		t.Error(err)
+	} else if header, err := tar.NewReader(gz).Next(); err != nil || header.Name == "" {
+		t.Errorf("compressed archive first entry %v, error %v", header, err)
+	}
+
+	if rec := serveRequest(TarballHandler(http.Dir(dir), "/other.txt"), http.MethodGet, "/download.tar.gz", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("file root: status %d, want 404", rec.Code)
+	}
+
+	if rec := serveRequest(TarballHandler(http.Dir(dir), "/docs/.git"), http.MethodGet, "/download.tar.gz", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("hidden root: status %d, want 404", rec.Code)
+	}
+}
+
+func TestTarballHandlerWellKnown(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, ".well-known/security.txt", "Contact: security@example.com")
+	writeTestFile(t, dir, ".well-known/.secret", "hidden")
+	writeTestFile(t, dir, ".env", "SECRET=1")
+	rec := serveRequest(TarballHandler(http.Dir(dir), "/"), http.MethodGet, "/download.tar.gz", nil)
+	files := readTarball(t, rec.Body)
+	want := map[string]string{".well-known/": "", ".well-known/security.txt": "Contact: security@example.com"}
+	if len(files) != len(want) {
+		t.Errorf("archive entries %v, want %v", files, want)
+	}
+	for name, content := range want {
+		if got, ok := files[name]; !ok || got != content {
+			t.Errorf("entry %q = %q (present %v), want %q", name, got, ok, content)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package webimizer
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+/*
+Transcode gzip compressed responses (for example, from upstream server behind ReverseProxyHandler or from cache) to encoding preferred by client: brotli (if NewBrotliWriter is set and client prefers br over gzip) or uncompressed (if client does not accept gzip). If client accepts gzip as much as other encodings, response is sent as is. Transcoded responses are streamed (Content-Length header is removed) and strong ETag is changed to weak one, but flushes of handler are not forwarded
+Example:
+
+	http.Handle("/", app.ReverseProxyHandler(target).WithTranscodeEncoding())
+*/
+func (fn HttpHandler) WithTranscodeEncoding() HttpHandler {
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		tw := &transcodeWriter{ResponseWriter: rw, r: r}
+		defer tw.Close()
+		fn(tw, r)
+	})
+}
+
+type transcodeWriter struct {
+	http.ResponseWriter
+	r       *http.Request
+	decided bool
+	pw      *io.PipeWriter
+	done    chan error
+}
+
+/*
+Get encoding, to which gzip response must be transcoded ("br", "identity" or empty string, if response must be sent as is)
+*/
+func transcodeTarget(acceptEncoding string) string {
+	gz := acceptEncodingQuality(acceptEncoding, "gzip")
+	if NewBrotliWriter != nil {
+		if br := acceptEncodingQuality(acceptEncoding, "br"); br > 0 && br > gz {
+			return "br"
+		}
+	}
+	if gz <= 0 && !identityForbidden(acceptEncoding) {
+		return "identity"
+	}
+	return ""
+}
+
+func (w *transcodeWriter) WriteHeader(status int) {
+	if w.decided || status < 200 {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	encoding := strings.ToLower(strings.TrimSpace(h.Get("Content-Encoding")))
+	if encoding != "gzip" && encoding != "x-gzip" {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	addVary(h, "Accept-Encoding")
+	target := transcodeTarget(w.r.Header.Get("Accept-Encoding"))
+	if target == "" || w.r.Method == http.MethodHead || status == http.StatusNoContent || status == http.StatusNotModified {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	h.Del("Content-Length")
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	if target == "identity" {
+		h.Del("Content-Encoding")
+	} else {
+		h.Set("Content-Encoding", target)
+	}
+	w.ResponseWriter.WriteHeader(status)
+	pr, pw := io.Pipe()
+	w.pw = pw
+	w.done = make(chan error, 1)
+	go func() {
+		err := transcode(w.ResponseWriter, pr, target)
+		// unblock Write of handler, if gzip stream is invalid
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+}
+
+/*
+Decompress gzip stream src and write it to w compressed with target encoding
+*/
+func transcode(w io.Writer, src io.Reader, target string) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	if target == "identity" {
+		_, err = io.Copy(w, gz)
+		return err
+	}
+	enc := NewBrotliWriter(w)
+	if _, err := io.Copy(enc, gz); err != nil {
+		enc.Close()
+		return err
+	}
+	return enc.Close()
+}
+
+func (w *transcodeWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.pw != nil {
+		return w.pw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+/*
+Finish transcoding of response body (it waits until transcoded body is written)
+*/
+func (w *transcodeWriter) Close() error {
+	if w.pw == nil {
+		return nil
+	}
+	w.pw.Close()
+	err := <-w.done
+	w.pw = nil
+	return err
+}
+
+/*
+Flush response, which is not transcoded
+*/
+func (w *transcodeWriter) Flush() {
+	if w.pw == nil {
+		flushResponse(w.ResponseWriter)
+	}
+}
+
+func (w *transcodeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,51 @@
+package webimizer
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func TestWithTranscodeEncoding(t *testing.T) {
+	setForTest(t, &NewBrotliWriter, func(w io.Writer) io.WriteCloser {
+		io.WriteString(w, "br(")
+		return stubBrotliWriter{w: w}
+	})
+	content := "upstream gzip content"
+	compressed := gzipBytes(t, []byte(content))
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		h := rw.Header()
+		h.Set("Content-Encoding", "gzip")
+		h.Set("Content-Length", strconv.Itoa(len(compressed)))
+		h.Set("ETag", `"v1"`)
+		rw.Write(compressed)
+	}).WithTranscodeEncoding()
+	tests := []struct {
+		acceptEncoding string
+		encoding       string
+		etag           string
+		body           string
+	}{
+		{"gzip;q=0.5, br", "br", `W/"v1"`, "br(" + content + ")"},
+		{"gzip, br", "gzip", `"v1"`, string(compressed)},
+		{"identity", "", `W/"v1"`, content},
+	}
+	for _, test := range tests {
+		rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {test.acceptEncoding}})
+		if got := rec.Header().Values("Content-Encoding"); len(got) > 1 || rec.Header().Get("Content-Encoding") != test.encoding {
+			t.Errorf("%q: Content-Encoding %q, want %q", test.acceptEncoding, got, test.encoding)
+		}
+		if got := rec.Header().Get("ETag"); got != test.etag {
+			t.Errorf("%q: ETag %q, want %q", test.acceptEncoding, got, test.etag)
+		}
+		if !bytes.Equal(rec.Body.Bytes(), []byte(test.body)) {
+			t.Errorf("%q: body %q, want %q", test.acceptEncoding, rec.Body.String(), test.body)
+		}
+		transcoded := test.encoding != "gzip"
+		if _, ok := rec.Header()["Content-Length"]; ok == transcoded {
+			t.Errorf("%q: Content-Length present %v, want %v", test.acceptEncoding, ok, !transcoded)
+		}
+	}
+}
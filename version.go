@@ -0,0 +1,36 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strconv"
+)
+
+/*
+Build information for VersionHandler (usually set by -ldflags "-X ..." at build time)
+*/
+type VersionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+/*
+Create HttpHandler, which returns build information as JSON (GoVersion is set from runtime.Version(), if empty). Response is not compressed
+*/
+func VersionHandler(info VersionInfo) HttpHandler {
+	if info.GoVersion == "" {
+		info.GoVersion = runtime.Version()
+	}
+	body, _ := json.Marshal(info)
+	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		h := rw.Header()
+		h.Set("Content-Type", "application/json")
+		h.Set("Content-Length", strconv.Itoa(len(body)))
+		h.Set("Cache-Control", "no-cache")
+		disableGzip(rw)
+		rw.Write(body)
+	})
+}
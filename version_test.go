@@ -0,0 +1,34 @@
+package webimizer
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"testing"
+)
+
+func TestVersionHandler(t *testing.T) {
+	handler := VersionHandler(VersionInfo{Version: "v1.2.3", Commit: "abc123", BuildTime: "2024-03-10T12:00:00Z"})
+	rec := serveRequest(handler, http.MethodGet, "/version", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Header().Get("Content-Encoding") != "" {
+		t.Errorf("got Content-Type %q Content-Encoding %q, want uncompressed JSON", rec.Header().Get("Content-Type"), rec.Header().Get("Content-Encoding"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"version": "v1.2.3", "commit": "abc123", "build_time": "2024-03-10T12:00:00Z", "go_version": runtime.Version()}
+	for key, value := range want {
+		if body[key] != value {
+			t.Errorf("%s = %q, want %q", key, body[key], value)
+		}
+	}
+
+	rec = serveRequest(handler, http.MethodGet, "/version", http.Header{"Accept-Encoding": {"gzip, identity;q=0"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("identity;q=0 got %d %q, want compressed 200", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if err := json.Unmarshal([]byte(gunzip(t, rec.Body.Bytes())), &body); err != nil || body["version"] != "v1.2.3" {
+		t.Errorf("compressed body version %q, error %v", body["version"], err)
+	}
+}
@@ -2,15 +2,15 @@ package webimizer
 
 import (
 	"compress/gzip"
+	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"path/filepath"
 	"strings"
+	"sync"
 )
 
 /*
-Define default Http Response headers
+Define default Http Response headers (use SetDefaultHTTPHeaders to change it while server is running)
 Example:
     [][]string{
 		{"x-content-type-options", "nosniff"},
@@ -20,22 +20,77 @@ Example:
 */
 var DefaultHTTPHeaders [][]string
 
-type gzipResponseWriter struct {
-	io.Writer
-	http.ResponseWriter
+var defaultHTTPHeadersMutex sync.RWMutex
+
+/*
+Replace DefaultHTTPHeaders. It is safe to call it while server is running
+*/
+func SetDefaultHTTPHeaders(headers [][]string) {
+	defaultHTTPHeadersMutex.Lock()
+	defer defaultHTTPHeadersMutex.Unlock()
+	DefaultHTTPHeaders = headers
 }
 
+/*
+Define Http Response headers, which can have multiple values in DefaultHTTPHeaders. All DefaultHTTPHeaders entries for these headers are added to Http response, unless response already has the same value (other headers are set, so the last entry wins)
+*/
+var MultiValueHTTPHeaders = []string{"Link", "Set-Cookie"}
+
+/*
+Define Http Response headers of DefaultHTTPHeaders, which are written with exact name casing (for example, "X-API-ID" instead of canonical "X-Api-Id") for legacy clients. Exact casing is kept by HTTP/1.x only (HTTP/2 always uses lowercase names)
+Example:
+
+	app.ExactCaseHTTPHeaders = []string{"X-API-ID"}
+*/
+var ExactCaseHTTPHeaders []string
+
+/*
+Define which request paths can be compressed with gzip (optional). If it is set and returns false for r.URL.Path, Http response is sent uncompressed even if Accept-Encoding request header contains gzip value. If client forbids uncompressed response (identity;q=0), response of such path gets 406 Not Acceptable status (unless it has no body or handler encodes it itself)
+Example:
+
+	app.GzipPathMatcher = func(path string) bool {
+		return strings.HasPrefix(path, "/api/") || strings.HasSuffix(path, ".html")
+	}
+*/
+var GzipPathMatcher func(path string) bool
+
 /*
 The main struct, where You can define Handler (it is main HttpHandler, which is called only, when Http method is allowed), NotAllowHandler (it is HttpHandler, which is called only if Http method is not allowed) and AllowedMethods ([]string array, which contains allowed HTTP method names)
 You must call func Build to build HttpHandler.
 
 In version v1.1 added AllowedOrigins field (optional): use if you want to check Origin header
+
+AllowedMethods can contain "*", which allows any Http method (AllowedOrigins are still checked)
+
+Vary (optional): request header names (for example, "X-Tenant"), on which response depends. They are added to Vary header without duplicates (Accept-Encoding is added by gzip compression and Origin is added, if AllowedOrigins is set)
+
+EmptyNotAllowBody (optional): if true and NotAllowHandler is not set, requests with not allowed method get 400 Bad Request status with empty body instead of "Bad Request" text (for example, for JSON APIs)
+
+AfterResponse (optional): func, which is called exactly once after response is finished (also if handler panics, then err describes panic and panic continues after the call, or client disconnects, then err is context error). Response compressed by ServeHTTP of this handler is finished (gzip stream is closed) before the call, so error of final write is passed as err too (gzip stream of enclosing handler, for example FallthroughHandler or WithBuffering, is finished by it after the call). status is sent status, bytes is uncompressed body size written by handler (for cleanup, auditing or metrics)
+
+AutoOptions (optional): if true, OPTIONS requests get 204 No Content status with Allow header (list of allowed methods) instead of NotAllowHandler. If OPTIONS is allowed explicitly (in AllowedMethods or by OptionsHandler), request is handled by handler as usual
+
+TimingAllowOrigin (optional): if true, requests with Origin header matched by AllowedOrigins get Timing-Allow-Origin response header with that origin, so the same origins are allowed to request and to read detailed Resource Timing of response
+
+Method handler fields GetHandler, HeadHandler, PostHandler, PutHandler, PatchHandler, DeleteHandler and OptionsHandler (optional) are called instead of Handler for matching Http method. Methods of set fields are added to AllowedMethods automatically. HEAD requests are handled by GetHandler, if HeadHandler is not set (so HEAD is allowed too). Allowed method without handler (no method field and no Handler) is handled as not allowed
 */
 type HttpHandlerStruct struct {
-	NotAllowHandler HttpNotAllowHandler
-	Handler         HttpHandler
-	AllowedMethods  []string
-	AllowedOrigins  []string
+	NotAllowHandler   HttpNotAllowHandler
+	Handler           HttpHandler
+	AllowedMethods    []string
+	AllowedOrigins    []string
+	GetHandler        HttpHandler
+	HeadHandler       HttpHandler
+	PostHandler       HttpHandler
+	PutHandler        HttpHandler
+	PatchHandler      HttpHandler
+	DeleteHandler     HttpHandler
+	OptionsHandler    HttpHandler
+	AutoOptions       bool
+	Vary              []string
+	EmptyNotAllowBody bool
+	AfterResponse     func(r *http.Request, status int, bytes int, err error)
+	TimingAllowOrigin bool
 }
 
 /*
@@ -124,10 +179,36 @@ type HttpNotAllowHandler func(http.ResponseWriter, *http.Request)
 Build HttpHandler, which can by used in http.Handle (but not in http.HandleFunc, because only http.Handle call ServeHTTP)
 */
 func (builder HttpHandlerStruct) Build() HttpHandler {
+	builder.AllowedMethods = builder.allowedMethods()
+	vary := append([]string{}, builder.Vary...)
+	if len(builder.AllowedOrigins) > 0 {
+		vary = append(vary, "Origin")
+	}
 	return HttpHandler(func(w http.ResponseWriter, r *http.Request) {
+		if builder.AfterResponse != nil {
+			sw := &countingResponseWriter{ResponseWriter: w}
+			gzr := ownedGzipResponseWriter(w, r)
+			defer builder.afterResponse(sw, gzr, r)
+			w = sw
+			if gzr != nil {
+				r = withoutGzipOwner(r)
+			}
+		}
+		for _, field := range vary {
+			addVary(w.Header(), field)
+		}
+		if builder.TimingAllowOrigin && r.Header.Get("Origin") != "" && builder.checkOrigins(r) {
+			w.Header().Set("Timing-Allow-Origin", r.Header.Get("Origin"))
+		}
 		builder.notAllowed(r, func(rw http.ResponseWriter, r *http.Request) {
-			if builder.NotAllowHandler != nil {
+			if builder.AutoOptions && r.Method == http.MethodOptions && !containsString(builder.AllowedMethods, http.MethodOptions) {
+				builder.autoOptions(rw)
+			} else if builder.NotAllowHandler != nil {
 				builder.NotAllowHandler(rw, r)
+			} else if builder.EmptyNotAllowBody {
+				// empty gzip stream is not empty body
+				disableGzip(rw)
+				rw.WriteHeader(http.StatusBadRequest)
 			} else {
 				fmt.Fprint(rw, "Bad Request")
 			}
@@ -141,31 +222,88 @@ It is main HttpHandler, which is called only, when Http method is allowed
 type HttpHandler func(http.ResponseWriter, *http.Request)
 
 /*
-Compressing Http response by using gzipResponseWriter (only if Accept-Encoding request header is set and contains gzip value) and also add DefaultHttpHeaders to Http response
+Compressing Http response by using gzipResponseWriter (only if Accept-Encoding request header is set and accepts gzip and GzipPathMatcher allows request path) and also add DefaultHttpHeaders to Http response. Response with Content-Encoding header (set by outer middleware before ServeHTTP or by handler before first Write) is never compressed again. Responses to clients, which do not accept gzip, get Vary: Accept-Encoding header too, if they would be compressed for clients, which accept gzip
 */
 func (fn HttpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	for _, v := range DefaultHTTPHeaders {
-		if len(v) == 2 {
-			w.Header().Set(v[0], v[1])
+	setDefaultHeaders(w.Header())
+	r = withoutGzipOwner(r)
+	if alreadyEncoded(w.Header()) || findGzipResponseWriter(w) != nil {
+		// response is already encoded (or compressed by outer HttpHandler), so it must not be compressed twice
+		fn(w, r)
+		return
+	}
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	// client refuses uncompressed response, so gzip is used even if it would be skipped
+	force := identityForbidden(acceptEncoding)
+	gzipAccepted := acceptEncodingQuality(acceptEncoding, "gzip") > 0
+	pathExcluded := GzipPathMatcher != nil && !GzipPathMatcher(r.URL.Path)
+	var gzr *gzipResponseWriter
+	if !gzipAccepted && !force {
+		if pathExcluded || GzipCompressionLevel < gzip.HuffmanOnly || GzipCompressionLevel > gzip.BestCompression {
+			fn(w, r)
+			return
 		}
+		// response is not compressed, but compression decision is made as for clients, which accept gzip, so Vary header is set
+		gzr = &gzipResponseWriter{ResponseWriter: w, varyOnly: true}
 	}
-	if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+	if pathExcluded && !force {
 		fn(w, r)
 		return
 	}
-	w.Header().Set("Content-Encoding", "gzip")
-	gz := gzip.NewWriter(w)
-	defer gz.Close()
-	gzr := gzipResponseWriter{Writer: gz, ResponseWriter: w}
-	fn(gzr, r)
+	if gzipAccepted && !pathExcluded {
+		var err error
+		if gzr, err = newGzipResponseWriter(w); err != nil && !force {
+			// can not compress, so Content-Encoding header must not be set
+			fn(w, r)
+			return
+		}
+	}
+	if gzr == nil {
+		// response can not be compressed, so 406 Not Acceptable status is sent, unless handler encodes response itself (for example, with br)
+		gzr = &gzipResponseWriter{ResponseWriter: w}
+	}
+	gzr.force = force
+	defer gzr.Close()
+	ctx := context.WithValue(context.WithValue(r.Context(), gzipWriterKey{}, gzr), gzipOwnerKey{}, gzr)
+	fn(gzr, r.WithContext(ctx))
+}
+
+func setDefaultHeaders(h http.Header) {
+	defaultHTTPHeadersMutex.RLock()
+	defer defaultHTTPHeadersMutex.RUnlock()
+	for _, v := range DefaultHTTPHeaders {
+		if len(v) != 2 {
+			continue
+		}
+		switch {
+		case containsString(ExactCaseHTTPHeaders, v[0]):
+			SetExactCaseHeader(h, v[0], v[1])
+		case isMultiValueHeader(v[0]):
+			// nested ServeHTTP call must not add the same value again
+			if !containsString(h.Values(v[0]), v[1]) {
+				h.Add(v[0], v[1])
+			}
+		default:
+			h.Set(v[0], v[1])
+		}
+	}
 }
 
-func (w gzipResponseWriter) Write(b []byte) (int, error) {
-	if w.Header().Get("Content-Type") == "" {
-		// If no content type, apply sniffing algorithm to un-gzipped body. Test
-		w.Header().Set("Content-Type", http.DetectContentType(b))
+/*
+Set header with exact name casing (http.Header.Set canonicalizes name). Header set by canonical name is replaced. Use it only for custom headers, because net/http reads standard headers (for example, Content-Type) by canonical name
+*/
+func SetExactCaseHeader(h http.Header, name, value string) {
+	h.Del(name)
+	h[name] = []string{value}
+}
+
+func isMultiValueHeader(key string) bool {
+	for _, multi := range MultiValueHTTPHeaders {
+		if strings.EqualFold(multi, key) {
+			return true
+		}
 	}
-	return w.Writer.Write(b)
+	return false
 }
 
 func (fn HttpHandlerStruct) checkOrigins(r *http.Request) bool {
@@ -180,63 +318,110 @@ func (fn HttpHandlerStruct) checkOrigins(r *http.Request) bool {
 func (fn HttpHandlerStruct) notAllowed(r *http.Request, notAllowed HttpHandler) HttpHandler {
 	hasOrigins := len(fn.AllowedOrigins) > 0
 	for _, method := range fn.AllowedMethods {
-		if method == r.Method && (!hasOrigins || fn.checkOrigins(r)) {
-			return fn.Handler
+		if (method == "*" || method == r.Method) && (!hasOrigins || fn.checkOrigins(r)) {
+			if handler := fn.methodHandler(r.Method); handler != nil {
+				return handler
+			}
+			break
 		}
 	}
 	return notAllowed
 }
 
+type methodHandler struct {
+	method  string
+	handler HttpHandler
+}
+
+func (fn HttpHandlerStruct) methodHandlers() []methodHandler {
+	return []methodHandler{
+		{http.MethodGet, fn.GetHandler},
+		{http.MethodHead, fn.HeadHandler},
+		{http.MethodPost, fn.PostHandler},
+		{http.MethodPut, fn.PutHandler},
+		{http.MethodPatch, fn.PatchHandler},
+		{http.MethodDelete, fn.DeleteHandler},
+		{http.MethodOptions, fn.OptionsHandler},
+	}
+}
+
 /*
-struct for serving filesystem
+Get handler for Http method: method handler field (if set), GetHandler for HEAD method or Handler (nil if none of them is set)
 */
-type neuteredFileSystem struct {
-	fs http.FileSystem
-	w  http.ResponseWriter
-	r  *http.Request
+func (fn HttpHandlerStruct) methodHandler(method string) HttpHandler {
+	for _, m := range fn.methodHandlers() {
+		if m.method == method && m.handler != nil {
+			return m.handler
+		}
+	}
+	if method == http.MethodHead && fn.GetHandler != nil {
+		// net/http discards body of HEAD response
+		return fn.GetHandler
+	}
+	return fn.Handler
 }
 
 /*
-Create http Handler for serving files in fsPath directory.
-If file not found return 404 status and serve error404.html if exist
+Get AllowedMethods with methods of set method handler fields
 */
-func NewFileServerHandler(fsPath string) HttpHandler {
-	return HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
-		http.FileServer(neuteredFileSystem{fs: http.Dir(fsPath), w: rw, r: r}).ServeHTTP(rw, r)
-	})
+func (fn HttpHandlerStruct) allowedMethods() []string {
+	methods := append([]string{}, fn.AllowedMethods...)
+	for _, m := range fn.methodHandlers() {
+		if m.handler != nil && !containsString(methods, m.method) {
+			methods = append(methods, m.method)
+		}
+	}
+	if fn.GetHandler != nil && !containsString(methods, http.MethodHead) {
+		methods = append(methods, http.MethodHead)
+	}
+	return methods
 }
 
 /*
-Read and send requested file to client
-If file not found return 404 status and serve 404 document file if error404.html exist
+Call AfterResponse hook (must be deferred, so panic of handler is recovered and panics again after hook is called)
 */
-func (nfs neuteredFileSystem) Open(path string) (http.File, error) {
-	errorHandler := func() (http.File, error) {
-		f, err := nfs.fs.Open("/error404.html")
-		if err != nil {
-			return nil, err
+func (fn HttpHandlerStruct) afterResponse(sw *countingResponseWriter, gzr *gzipResponseWriter, r *http.Request) {
+	p := recover()
+	var err error
+	if gzr != nil && p == nil {
+		// compressed (or buffered by GzipMinLength) response is finished, when gzip stream is closed
+		err = gzr.Close()
+	}
+	status := sw.Status()
+	if gzr := findGzipResponseWriter(sw); gzr != nil && gzr.rejected {
+		status = http.StatusNotAcceptable
+	}
+	switch {
+	case p != nil:
+		err = fmt.Errorf("webimizer: handler panic: %v", p)
+		if !sw.Written() {
+			status = http.StatusInternalServerError
 		}
-		nfs.w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		nfs.w.WriteHeader(http.StatusNotFound)
-		return f, nil
-	}
-	f, err := nfs.fs.Open(path)
-	if err != nil {
-		return errorHandler()
-	}
-
-	s, _ := f.Stat()
-	if s.IsDir() {
-		index := filepath.Join(path, "index.html")
-		if _, err := nfs.fs.Open(index); err != nil {
-			closeErr := f.Close()
-			if closeErr != nil {
-				return errorHandler()
-			}
+	case err != nil:
+	case r.Context().Err() != nil:
+		// client disconnected or request deadline exceeded
+		err = r.Context().Err()
+	}
+	fn.AfterResponse(r, status, int(sw.BytesWritten()), err)
+	if p != nil {
+		panic(p)
+	}
+}
 
-			return errorHandler()
+/*
+Respond to OPTIONS request, which is not handled by handler
+*/
+func (fn HttpHandlerStruct) autoOptions(rw http.ResponseWriter) {
+	methods := append(append([]string{}, fn.AllowedMethods...), http.MethodOptions)
+	rw.Header().Set("Allow", strings.Join(methods, ", "))
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
 	}
-
-	return f, nil
+	return false
 }
@@ -0,0 +1,480 @@
+package webimizer
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type afterResponseCall struct {
+	status  int
+	bytes   int
+	err     error
+	written int
+}
+
+func TestAfterResponseSuccess(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	var calls []afterResponseCall
+	rec := httptest.NewRecorder()
+	handler := HttpHandlerStruct{
+		AllowedMethods: []string{http.MethodGet},
+		Handler: func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Set("Content-Type", "text/plain")
+			rw.WriteHeader(http.StatusCreated)
+			io.WriteString(rw, "small body")
+		},
+		AfterResponse: func(r *http.Request, status int, bytes int, err error) {
+			calls = append(calls, afterResponseCall{status: status, bytes: bytes, err: err, written: rec.Body.Len()})
+		},
+	}.Build()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, r)
+	if len(calls) != 1 {
+		t.Fatalf("AfterResponse called %d times, want 1", len(calls))
+	}
+	call := calls[0]
+	if call.status != http.StatusCreated || call.bytes != len("small body") || call.err != nil {
+		t.Errorf("AfterResponse(%d, %d, %v), want (201, %d, nil)", call.status, call.bytes, call.err, len("small body"))
+	}
+	if call.written != len("small body") {
+		t.Errorf("response body had %d bytes, when hook was called, want buffered body to be sent", call.written)
+	}
+}
+
+func TestAfterResponseEnclosingGzip(t *testing.T) {
+	content := strings.Repeat("fallback content ", 100)
+	var statuses []int
+	primary := HttpHandlerStruct{
+		AllowedMethods: []string{http.MethodGet},
+		Handler:        http.NotFound,
+		AfterResponse: func(r *http.Request, status int, bytes int, err error) {
+			if err != nil {
+				t.Errorf("AfterResponse error %v", err)
+			}
+			statuses = append(statuses, status)
+		},
+	}.Build()
+	buffered := HttpHandlerStruct{
+		AllowedMethods: []string{http.MethodGet},
+		Handler: func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, content)
+		},
+		AfterResponse: func(r *http.Request, status int, bytes int, err error) {},
+	}.Build().WithBuffering()
+	handlers := map[string]HttpHandler{
+		"FallthroughHandler": FallthroughHandler(primary, func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, content)
+		}),
+		"WithBuffering": buffered,
+	}
+	for name, handler := range handlers {
+		for _, acceptEncoding := range []string{"gzip", "gzip, identity;q=0"} {
+			rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {acceptEncoding}})
+			if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+				t.Errorf("%s with %q: status %d, Content-Encoding %q, want compressed 200", name, acceptEncoding, rec.Code, rec.Header().Get("Content-Encoding"))
+				continue
+			}
+			if got := gunzip(t, rec.Body.Bytes()); got != content {
+				t.Errorf("%s with %q: decompressed body is %d bytes, want %d", name, acceptEncoding, len(got), len(content))
+			}
+		}
+	}
+	if len(statuses) != 2 || statuses[0] != http.StatusNotFound {
+		t.Errorf("primary AfterResponse statuses %v, want 404 twice", statuses)
+	}
+}
+
+func TestAfterResponseCompressed(t *testing.T) {
+	var written int
+	rec := httptest.NewRecorder()
+	handler := HttpHandlerStruct{
+		AllowedMethods: []string{http.MethodGet},
+		Handler: func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, strings.Repeat("compressed ", 100))
+		},
+		AfterResponse: func(r *http.Request, status int, bytes int, err error) {
+			written = rec.Body.Len()
+		},
+	}.Build()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, r)
+	if written == 0 || written != rec.Body.Len() {
+		t.Errorf("hook saw %d of %d compressed bytes", written, rec.Body.Len())
+	}
+	if gunzip(t, rec.Body.Bytes()) != strings.Repeat("compressed ", 100) {
+		t.Error("compressed body is invalid")
+	}
+}
+
+func TestAfterResponsePanic(t *testing.T) {
+	var calls []afterResponseCall
+	handler := HttpHandlerStruct{
+		AllowedMethods: []string{http.MethodGet},
+		Handler: func(rw http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		},
+		AfterResponse: func(r *http.Request, status int, bytes int, err error) {
+			calls = append(calls, afterResponseCall{status: status, bytes: bytes, err: err})
+		},
+	}.Build()
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want original panic", p)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	if len(calls) != 1 {
+		t.Fatalf("AfterResponse called %d times, want 1", len(calls))
+	}
+	if calls[0].status != http.StatusInternalServerError || calls[0].err == nil || !strings.Contains(calls[0].err.Error(), "boom") {
+		t.Errorf("AfterResponse(%d, %v), want 500 with panic error", calls[0].status, calls[0].err)
+	}
+}
+
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w failingWriter) Write(b []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func TestAfterResponseGzipCloseError(t *testing.T) {
+	var gotErr error
+	handler := HttpHandlerStruct{
+		AllowedMethods: []string{http.MethodGet},
+		Handler: func(rw http.ResponseWriter, r *http.Request) {
+			io.WriteString(rw, "body")
+		},
+		AfterResponse: func(r *http.Request, status int, bytes int, err error) {
+			gotErr = err
+		},
+	}.Build()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(failingWriter{httptest.NewRecorder()}, r)
+	if !errors.Is(gotErr, errWriteFailed) {
+		t.Errorf("AfterResponse err = %v, want final gzip write error", gotErr)
+	}
+}
+
+func methodNameHandler(name string) HttpHandler {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Handler", name)
+	}
+}
+
+func TestMethodHandlers(t *testing.T) {
+	handler := HttpHandlerStruct{
+		Handler:        methodNameHandler("handler"),
+		GetHandler:     methodNameHandler("get"),
+		PostHandler:    methodNameHandler("post"),
+		PutHandler:     methodNameHandler("put"),
+		PatchHandler:   methodNameHandler("patch"),
+		DeleteHandler:  methodNameHandler("delete"),
+		OptionsHandler: methodNameHandler("options"),
+		AllowedMethods: []string{"REPORT"},
+	}.Build()
+	tests := []struct {
+		method string
+		want   string
+	}{
+		{http.MethodGet, "get"},
+		{http.MethodHead, "get"},
+		{http.MethodPost, "post"},
+		{http.MethodPut, "put"},
+		{http.MethodPatch, "patch"},
+		{http.MethodDelete, "delete"},
+		{http.MethodOptions, "options"},
+		{"REPORT", "handler"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			rec := serveRequest(handler, tt.method, "/", nil)
+			if got := rec.Header().Get("X-Handler"); got != tt.want {
+				t.Errorf("%s handled by %q, want %q", tt.method, got, tt.want)
+			}
+		})
+	}
+	if rec := serveRequest(handler, http.MethodTrace, "/", nil); rec.Code != http.StatusOK || rec.Body.String() != "Bad Request" {
+		t.Errorf("TRACE got %d %q, want not allowed", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMethodHandlersWithoutHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder HttpHandlerStruct
+		method  string
+		want    string
+	}{
+		{"head uses get handler", HttpHandlerStruct{GetHandler: methodNameHandler("get"), AllowedMethods: []string{http.MethodHead}}, http.MethodHead, "get"},
+		{"head allowed by get handler", HttpHandlerStruct{GetHandler: methodNameHandler("get")}, http.MethodHead, "get"},
+		{"wildcard without handler", HttpHandlerStruct{GetHandler: methodNameHandler("get"), AllowedMethods: []string{"*"}}, http.MethodPost, ""},
+		{"allowed method without handler", HttpHandlerStruct{PostHandler: methodNameHandler("post"), AllowedMethods: []string{http.MethodPut}}, http.MethodPut, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveRequest(tt.builder.Build(), tt.method, "/", nil)
+			if got := rec.Header().Get("X-Handler"); got != tt.want {
+				t.Errorf("handled by %q, want %q", got, tt.want)
+			}
+			if tt.want == "" && rec.Body.String() != "Bad Request" {
+				t.Errorf("body = %q, want not allowed response", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestGzipPathMatcher(t *testing.T) {
+	setForTest(t, &GzipPathMatcher, func(path string) bool {
+		return strings.HasPrefix(path, "/api/") || strings.HasSuffix(path, ".html")
+	})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		io.WriteString(rw, "response of "+r.URL.Path)
+	})
+	tests := []struct {
+		path       string
+		compressed bool
+	}{
+		{"/api/users", true},
+		{"/index.html", true},
+		{"/app.js", false},
+		{"/images/logo.png", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serveRequest(handler, http.MethodGet, tt.path, http.Header{"Accept-Encoding": {"gzip"}})
+			if compressed := rec.Header().Get("Content-Encoding") == "gzip"; compressed != tt.compressed {
+				t.Fatalf("compressed = %v, want %v", compressed, tt.compressed)
+			}
+			body := rec.Body.String()
+			if tt.compressed {
+				body = gunzip(t, rec.Body.Bytes())
+			}
+			if body != "response of "+tt.path {
+				t.Errorf("body = %q", body)
+			}
+		})
+	}
+}
+
+func TestGzipPathMatcherIdentityForbidden(t *testing.T) {
+	setForTest(t, &GzipPathMatcher, func(path string) bool {
+		return strings.HasPrefix(path, "/api/")
+	})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/empty" {
+			rw.WriteHeader(http.StatusNoContent)
+			return
+		}
+		io.WriteString(rw, "response of "+r.URL.Path)
+	})
+	header := http.Header{"Accept-Encoding": {"gzip, identity;q=0"}}
+	rec := serveRequest(handler, http.MethodGet, "/api/users", header)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("/api/users: status %d, Content-Encoding %q, want compressed 200", rec.Code, rec.Header().Get("Content-Encoding"))
+	}
+	if body := gunzip(t, rec.Body.Bytes()); body != "response of /api/users" {
+		t.Errorf("/api/users body = %q", body)
+	}
+	rec = serveRequest(handler, http.MethodGet, "/app.js", header)
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("/app.js: status %d, want 406", rec.Code)
+	}
+	if rec.Header().Get("Content-Encoding") != "" || strings.Contains(rec.Body.String(), "response of") {
+		t.Error("/app.js: excluded path is sent although client forbids uncompressed response")
+	}
+	rec = serveRequest(handler, http.MethodGet, "/empty", header)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("/empty: status %d, want 204", rec.Code)
+	}
+}
+
+func TestDefaultHTTPHeadersMultiValue(t *testing.T) {
+	setForTest(t, &DefaultHTTPHeaders, [][]string{
+		{"Link", "</app.css>; rel=preload; as=style"},
+		{"link", "</app.js>; rel=preload; as=script"},
+		{"X-Frame-Options", "DENY"},
+		{"X-Frame-Options", "SAMEORIGIN"},
+	})
+	rec := serveRequest(HttpHandler(func(rw http.ResponseWriter, r *http.Request) {}), http.MethodGet, "/", nil)
+	links := rec.Header().Values("Link")
+	if len(links) != 2 || links[0] != "</app.css>; rel=preload; as=style" || links[1] != "</app.js>; rel=preload; as=script" {
+		t.Errorf("Link headers = %q, want both defaults", links)
+	}
+	if got := rec.Header().Values("X-Frame-Options"); len(got) != 1 || got[0] != "SAMEORIGIN" {
+		t.Errorf("X-Frame-Options = %q, want last default only", got)
+	}
+}
+
+func TestExactCaseHTTPHeaders(t *testing.T) {
+	setForTest(t, &DefaultHTTPHeaders, [][]string{{"X-API-ID", "abc"}, {"X-Frame-Options", "DENY"}})
+	setForTest(t, &ExactCaseHTTPHeaders, []string{"X-API-ID"})
+	server := httptest.NewServer(HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		SetExactCaseHeader(rw.Header(), "x-request-TAG", "t1")
+	}))
+	defer server.Close()
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+	raw, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := string(raw)
+	for _, line := range []string{"\r\nX-API-ID: abc\r\n", "\r\nx-request-TAG: t1\r\n", "\r\nX-Frame-Options: DENY\r\n"} {
+		if !strings.Contains(response, line) {
+			t.Errorf("response has no %q header line:\n%s", strings.TrimSpace(line), response)
+		}
+	}
+	if strings.Contains(response, "X-Api-Id") {
+		t.Error("canonical X-Api-Id header is sent too")
+	}
+}
+
+func TestDefaultHTTPHeadersMultiValueNested(t *testing.T) {
+	setForTest(t, &DefaultHTTPHeaders, [][]string{
+		{"Link", "</app.css>; rel=preload; as=style"},
+		{"Set-Cookie", "consent=1; Path=/"},
+	})
+	inner := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Add("Link", "</page.js>; rel=preload; as=script")
+		io.WriteString(rw, "page")
+	})
+	handler := HttpHandler(func(rw http.ResponseWriter, r *http.Request) {
+		http.StripPrefix("/app", inner).ServeHTTP(rw, r)
+	})
+	rec := serveRequest(handler, http.MethodGet, "/app/page", http.Header{"Accept-Encoding": {"gzip"}})
+	if got := rec.Header().Values("Link"); !reflect.DeepEqual(got, []string{"</app.css>; rel=preload; as=style", "</page.js>; rel=preload; as=script"}) {
+		t.Errorf("Link headers = %q, want default once and handler link", got)
+	}
+	if got := rec.Header().Values("Set-Cookie"); len(got) != 1 {
+		t.Errorf("Set-Cookie headers = %q, want default once", got)
+	}
+}
+
+func TestVary(t *testing.T) {
+	setForTest(t, &GzipMinLength, 1024)
+	handler := HttpHandlerStruct{
+		Handler: func(rw http.ResponseWriter, r *http.Request) {
+			addVary(rw.Header(), "x-tenant")
+			io.WriteString(rw, strings.Repeat("a", 2048))
+		},
+		AllowedMethods: []string{http.MethodGet},
+		AllowedOrigins: []string{"https://example.com"},
+		Vary:           []string{"X-Tenant", "Accept-Language"},
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Accept-Encoding": {"gzip"}, "Origin": {"https://example.com"}})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatal("response is not compressed")
+	}
+	got := strings.Join(rec.Header().Values("Vary"), ", ")
+	if want := "X-Tenant, Accept-Language, Origin, Accept-Encoding"; got != want {
+		t.Errorf("Vary = %q, want %q", got, want)
+	}
+}
+
+func TestEmptyNotAllowBody(t *testing.T) {
+	setForTest(t, &DefaultHTTPHeaders, [][]string{{"X-Frame-Options", "DENY"}})
+	handler := HttpHandlerStruct{
+		Handler:           methodNameHandler("handler"),
+		AllowedMethods:    []string{http.MethodGet},
+		EmptyNotAllowBody: true,
+	}.Build()
+	for _, acceptEncoding := range []string{"gzip", "gzip, identity;q=0", "br, identity;q=0"} {
+		rec := serveRequest(handler, http.MethodPost, "/", http.Header{"Accept-Encoding": {acceptEncoding}})
+		if rec.Code != http.StatusBadRequest || rec.Body.Len() != 0 {
+			t.Errorf("%q: status %d, body %q, want 400 with empty body", acceptEncoding, rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("X-Frame-Options") != "DENY" || rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%q: X-Frame-Options %q, Content-Encoding %q, want default headers without encoding", acceptEncoding, rec.Header().Get("X-Frame-Options"), rec.Header().Get("Content-Encoding"))
+		}
+	}
+	if got := serveRequest(handler, http.MethodGet, "/", nil).Header().Get("X-Handler"); got != "handler" {
+		t.Errorf("GET handled by %q, want handler", got)
+	}
+}
+
+func TestAllowedMethodsWildcard(t *testing.T) {
+	handler := HttpHandlerStruct{
+		Handler:        methodNameHandler("handler"),
+		AllowedMethods: []string{"*"},
+	}.Build()
+	for _, method := range []string{"REPORT", "PROPFIND", http.MethodPost} {
+		if got := serveRequest(handler, method, "/", nil).Header().Get("X-Handler"); got != "handler" {
+			t.Errorf("%s handled by %q, want handler", method, got)
+		}
+	}
+
+	withOrigins := HttpHandlerStruct{
+		Handler:        methodNameHandler("handler"),
+		AllowedMethods: []string{"*"},
+		AllowedOrigins: []string{"https://example.com"},
+	}.Build()
+	if got := serveRequest(withOrigins, "REPORT", "/", http.Header{"Origin": {"https://example.com"}}).Header().Get("X-Handler"); got != "handler" {
+		t.Errorf("REPORT from allowed origin handled by %q, want handler", got)
+	}
+	rec := serveRequest(withOrigins, "REPORT", "/", http.Header{"Origin": {"https://evil.com"}})
+	if got := rec.Header().Get("X-Handler"); got != "" || rec.Body.String() != "Bad Request" {
+		t.Errorf("REPORT from disallowed origin handled by %q with body %q, want not allowed", got, rec.Body.String())
+	}
+}
+
+func TestTimingAllowOrigin(t *testing.T) {
+	handler := HttpHandlerStruct{
+		Handler:           methodNameHandler("handler"),
+		AllowedMethods:    []string{http.MethodGet},
+		AllowedOrigins:    []string{"https://example.com", "https://www.example.com"},
+		TimingAllowOrigin: true,
+	}.Build()
+	rec := serveRequest(handler, http.MethodGet, "/", http.Header{"Origin": {"https://www.example.com"}})
+	if got := rec.Header().Get("Timing-Allow-Origin"); got != "https://www.example.com" {
+		t.Errorf("allowed origin Timing-Allow-Origin = %q, want https://www.example.com", got)
+	}
+	for _, header := range []http.Header{{"Origin": {"https://evil.com"}}, nil} {
+		if got := serveRequest(handler, http.MethodGet, "/", header).Header().Get("Timing-Allow-Origin"); got != "" {
+			t.Errorf("origin %q Timing-Allow-Origin = %q, want absent", header.Get("Origin"), got)
+		}
+	}
+}
+
+func TestAutoOptions(t *testing.T) {
+	explicit := HttpHandlerStruct{
+		Handler:        methodNameHandler("handler"),
+		AllowedMethods: []string{http.MethodGet, http.MethodOptions},
+		AutoOptions:    true,
+	}.Build()
+	rec := serveRequest(explicit, http.MethodOptions, "/", nil)
+	if got := rec.Header().Get("X-Handler"); got != "handler" || rec.Code != http.StatusOK {
+		t.Errorf("explicit OPTIONS got %d handled by %q, want handler", rec.Code, got)
+	}
+
+	auto := HttpHandlerStruct{
+		Handler:        methodNameHandler("handler"),
+		AllowedMethods: []string{http.MethodGet, http.MethodPost},
+		AutoOptions:    true,
+	}.Build()
+	rec = serveRequest(auto, http.MethodOptions, "/", nil)
+	if rec.Code != http.StatusNoContent || rec.Header().Get("X-Handler") != "" {
+		t.Errorf("automatic OPTIONS got %d handled by %q, want 204 without handler", rec.Code, rec.Header().Get("X-Handler"))
+	}
+	if allow := rec.Header().Get("Allow"); allow != "GET, POST, OPTIONS" {
+		t.Errorf("Allow = %q, want %q", allow, "GET, POST, OPTIONS")
+	}
+}